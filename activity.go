@@ -22,6 +22,10 @@ var rescopedTpl = template.Must(
 	---{{"\n"}}
 	{{.Message}}{{"\n"}}
 	---
+	{{range .Files}}
+		{{"\n"}}
+		{{.WithPath}}
+	{{end}}
 	{{if not (last $i $.Data)}}
 	{{"\n\n"}}
 	{{end}}
@@ -53,6 +57,56 @@ var reopenedTpl = template.Must(
 Pull request reopened by: {{.User.DisplayName}} <{{.User.EmailAddress}}>
 `)))
 
+var unapprovedTpl = template.Must(
+	template.New(`unapproved`).Parse(tplutil.Strip(`
+Pull request unapproved by: {{.User.DisplayName}} <{{.User.EmailAddress}}>
+`)))
+
+var reviewedTpl = template.Must(
+	template.New(`reviewed`).Parse(tplutil.Strip(`
+Pull request marked as needs work by: {{.User.DisplayName}}
+{{" "}}<{{.User.EmailAddress}}>
+`)))
+
+var deletedTpl = template.Must(
+	template.New(`deleted`).Parse(tplutil.Strip(`
+Pull request deleted by: {{.User.DisplayName}} <{{.User.EmailAddress}}>
+`)))
+
+var updatedTpl = template.Must(
+	template.New(`updated`).Parse(tplutil.Strip(`
+Pull request updated by: {{.User.DisplayName}} <{{.User.EmailAddress}}>
+{{range .AddedReviewers}}
+	{{"\n"}}
+	Reviewer added: {{.DisplayName}} <{{.EmailAddress}}>
+{{end}}
+{{range .RemovedReviewers}}
+	{{"\n"}}
+	Reviewer removed: {{.DisplayName}} <{{.EmailAddress}}>
+{{end}}
+{{if .PreviousToRef.DisplayId}}
+	{{"\n"}}
+	Target branch changed from: {{.PreviousToRef.DisplayId}}
+{{end}}
+{{if .PreviousTitle}}
+	{{"\n"}}
+	Title changed from: {{.PreviousTitle}}
+{{end}}
+{{if .PreviousDescription}}
+	{{"\n"}}
+	Description changed from:{{"\n"}}
+	---{{"\n"}}
+	{{.PreviousDescription}}{{"\n"}}
+	---
+{{end}}
+`)))
+
+var unknownActionTpl = template.Must(
+	template.New(`unknown`).Parse(tplutil.Strip(`
+Pull request activity {{.Action}} by: {{.User.DisplayName}}
+{{" "}}<{{.User.EmailAddress}}>
+`)))
+
 var commentOnFileTpl = template.Must(
 	template.New(`filecomment`).Parse(tplutil.Strip(`
 {{.Comment.Author.DisplayName}} commented on file {{.CommentAnchor.Path}}:
@@ -60,6 +114,30 @@ var commentOnFileTpl = template.Must(
 
 type ReviewActivity struct {
 	godiff.Changeset
+	Reactions CommentReactions
+
+	// Filter can be set before unmarshalling to skip activities.
+	Filter ActivityFilter
+
+	// LastId is the id of the newest activity and Count is the number of
+	// activities matched by Filter.
+	LastId int64
+	Count  int
+
+	rescopes []*reviewActionRescoped
+}
+
+type activityUser struct {
+	Name         string
+	EmailAddress string
+	DisplayName  string
+}
+
+type activityHead struct {
+	Id          int64
+	Action      string
+	CreatedDate UnixTimestamp
+	User        activityUser
 }
 
 type reviewAction interface {
@@ -77,7 +155,20 @@ type reviewActionCommented struct {
 }
 
 type reviewActionRescoped struct {
-	diff *godiff.Diff
+	diff  *godiff.Diff
+	value struct {
+		CreatedDate      UnixTimestamp
+		FromHash         string
+		PreviousFromHash string
+		PreviousToHash   string
+		ToHash           string
+		Added            struct {
+			Commits []rescopedChangeset
+		}
+		Removed struct {
+			Commits []rescopedChangeset
+		}
+	}
 }
 
 type rescopedChangeset struct {
@@ -91,6 +182,9 @@ type rescopedChangeset struct {
 		DisplayName  string
 	}
 	Message string
+
+	// Files are populated only for added commits by describeRescopes.
+	Files ReviewFiles `json:"-"`
 }
 
 func (activity *ReviewActivity) UnmarshalJSON(data []byte) error {
@@ -100,13 +194,29 @@ func (activity *ReviewActivity) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	err = json.Unmarshal(data, &activity.Reactions)
+	if err != nil {
+		return err
+	}
+
 	for _, rawActivity := range values {
-		head := struct{ Action string }{}
+		head := activityHead{}
+
 		err := json.Unmarshal(rawActivity, &head)
 		if err != nil {
 			return err
 		}
 
+		if head.Id > activity.LastId {
+			activity.LastId = head.Id
+		}
+
+		if !activity.Filter.Match(head) {
+			continue
+		}
+
+		activity.Count++
+
 		var diff *godiff.Diff
 		var value reviewAction
 
@@ -114,7 +224,9 @@ func (activity *ReviewActivity) UnmarshalJSON(data []byte) error {
 		case "COMMENTED":
 			value = &reviewActionCommented{}
 		case "RESCOPED":
-			value = &reviewActionRescoped{}
+			rescoped := &reviewActionRescoped{}
+			activity.rescopes = append(activity.rescopes, rescoped)
+			value = rescoped
 		default:
 			value = &reviewActionBasic{Action: head.Action}
 		}
@@ -199,44 +311,38 @@ func (rc *reviewActionCommented) GetDiff() *godiff.Diff {
 }
 
 func (rr *reviewActionRescoped) UnmarshalJSON(data []byte) error {
-	value := struct {
-		CreatedDate      UnixTimestamp
-		FromHash         string
-		PreviousFromHash string
-		PreviousToHash   string
-		ToHash           string
-		Added            struct {
-			Commits []rescopedChangeset
-		}
-		Removed struct {
-			Commits []rescopedChangeset
-		}
-	}{}
-
-	err := json.Unmarshal(data, &value)
+	err := json.Unmarshal(data, &rr.value)
 	if err != nil {
 		return err
 	}
 
+	rr.diff = &godiff.Diff{}
+
+	return rr.render()
+}
+
+// render (re-)renders note of rescoped activity, so it can be updated after
+// commits are populated with changed files.
+func (rr *reviewActionRescoped) render() error {
 	components := []struct {
 		Data   []rescopedChangeset
 		Prefix string
 	}{
-		{value.Added.Commits, "+"},
-		{value.Removed.Commits, "-"},
+		{rr.value.Added.Commits, "+"},
+		{rr.value.Removed.Commits, "-"},
 	}
 
 	header, err := tplutil.ExecuteToString(updatedHeaderTpl, struct {
 		Date UnixTimestamp
 	}{
-		value.CreatedDate,
+		rr.value.CreatedDate,
 	})
 
 	if err != nil {
 		return err
 	}
 
-	rr.diff = &godiff.Diff{}
+	rr.diff.Note = ""
 	for _, val := range components {
 		if len(val.Data) > 0 {
 			result, err := tplutil.ExecuteToString(rescopedTpl, val)
@@ -274,18 +380,34 @@ func (rb *reviewActionBasic) UnmarshalJSON(data []byte) error {
 		tpl = declinedTpl
 	case "REOPENED":
 		tpl = reopenedTpl
+	case "UNAPPROVED":
+		tpl = unapprovedTpl
+	case "REVIEWED":
+		tpl = reviewedTpl
+	case "DELETED":
+		tpl = deletedTpl
+	case "UPDATED":
+		tpl = updatedTpl
 	default:
 		logger.Warning("unknown activity action: '%s'", rb.Action)
-		return nil
+		tpl = unknownActionTpl
 	}
 
 	value := struct {
+		Action      string
 		CreatedDate int64
-		User        struct {
-			EmailAddress string
-			DisplayName  string
+		User        activityUser
+
+		AddedReviewers      []activityUser
+		RemovedReviewers    []activityUser
+		PreviousTitle       string
+		PreviousDescription string
+		PreviousToRef       struct {
+			DisplayId string
 		}
-	}{}
+	}{
+		Action: rb.Action,
+	}
 
 	err := json.Unmarshal(data, &value)
 	if err != nil {
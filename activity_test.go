@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReviewActivityAllActions(t *testing.T) {
+	activity := ReviewActivity{}
+
+	err := json.Unmarshal([]byte(`[
+		{
+			"id": 9, "action": "DECLINED",
+			"user": {"displayName": "Bob", "emailAddress": "bob@local"}
+		},
+		{
+			"id": 8, "action": "REOPENED",
+			"user": {"displayName": "Alice", "emailAddress": "alice@local"}
+		},
+		{
+			"id": 7, "action": "DELETED",
+			"user": {"displayName": "Alice", "emailAddress": "alice@local"}
+		},
+		{
+			"id": 6, "action": "UNAPPROVED",
+			"user": {"displayName": "Bob", "emailAddress": "bob@local"}
+		},
+		{
+			"id": 5, "action": "REVIEWED",
+			"user": {"displayName": "Bob", "emailAddress": "bob@local"}
+		},
+		{
+			"id": 4, "action": "UPDATED",
+			"user": {"displayName": "Alice", "emailAddress": "alice@local"},
+			"addedReviewers": [
+				{"displayName": "Carol", "emailAddress": "carol@local"}
+			],
+			"removedReviewers": [
+				{"displayName": "Dave", "emailAddress": "dave@local"}
+			],
+			"previousToRef": {"displayId": "develop"}
+		},
+		{
+			"id": 3, "action": "UPDATED",
+			"user": {"displayName": "Alice", "emailAddress": "alice@local"},
+			"previousTitle": "WIP", "previousDescription": "TBD"
+		},
+		{
+			"id": 2, "action": "TRANSCENDED",
+			"user": {"displayName": "Alice", "emailAddress": "alice@local"}
+		},
+		{
+			"id": 1, "action": "OPENED",
+			"user": {"displayName": "Alice", "emailAddress": "alice@local"}
+		}
+	]`), &activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Pull request declined by: Bob <bob@local>",
+		"Pull request reopened by: Alice <alice@local>",
+		"Pull request deleted by: Alice <alice@local>",
+		"Pull request unapproved by: Bob <bob@local>",
+		"Pull request marked as needs work by: Bob <bob@local>",
+		"Pull request updated by: Alice <alice@local>\n" +
+			"Reviewer added: Carol <carol@local>\n" +
+			"Reviewer removed: Dave <dave@local>\n" +
+			"Target branch changed from: develop",
+		"Pull request updated by: Alice <alice@local>\n" +
+			"Title changed from: WIP\n" +
+			"Description changed from:\n---\nTBD\n---",
+		"Pull request activity TRANSCENDED by: Alice <alice@local>",
+		"Pull request opened by: Alice <alice@local>",
+	}
+
+	if len(activity.Diffs) != len(expected) {
+		t.Fatalf("every activity should be shown, got %d", len(activity.Diffs))
+	}
+
+	for i, diff := range activity.Diffs {
+		if diff.Note != expected[i] {
+			t.Errorf("unexpected note\n%q\n%q", expected[i], diff.Note)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	reOptionWithValue  = regexp.MustCompile(`(?m)^\s+(?:(-\w) )?(-[\w-]+)=<`)
+	reAliasPlaceholder = regexp.MustCompile(`\$(\d+)`)
+	reUsageLine        = regexp.MustCompile(`(?m)^\s+ash (.*)$`)
+	reUsageArgument    = regexp.MustCompile(`<[^>]*>|\[options\]`)
+	reUsageCommand     = regexp.MustCompile(`(?:^|[\s(|\[])([a-z][a-z-]*)`)
+)
+
+// getOptionsWithValue returns short and long names of options, which take
+// value, like -u and --user for '-u --user=<user>'.
+func getOptionsWithValue(help string) map[string]bool {
+	withValues := map[string]bool{}
+	for _, matches := range reOptionWithValue.FindAllStringSubmatch(help, -1) {
+		withValues[matches[1]] = true
+		withValues[matches[2]] = true
+	}
+
+	return withValues
+}
+
+// ExpandAlias replaces first positional argument with the alias value, if
+// it is an alias name. Placeholders like $1 are replaced with arguments
+// following alias name, rest of arguments are appended to the expansion.
+func ExpandAlias(
+	cmd []string, aliases map[string]string, help string,
+) ([]string, error) {
+	withValues := getOptionsWithValue(help)
+
+	for i := 0; i < len(cmd); i++ {
+		arg := cmd[i]
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && withValues[arg] {
+				i++
+			}
+
+			continue
+		}
+
+		alias, ok := aliases[arg]
+		if !ok {
+			return cmd, nil
+		}
+
+		expansion, err := expandAliasValue(arg, alias, cmd[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug("alias '%s' is expanded to %v", arg, expansion)
+
+		result := append([]string{}, cmd[:i]...)
+		return append(result, expansion...), nil
+	}
+
+	return cmd, nil
+}
+
+func expandAliasValue(
+	name string, alias string, args []string,
+) ([]string, error) {
+	used := 0
+
+	expansion := []string{}
+	for _, token := range strings.Fields(alias) {
+		var err error
+		token = reAliasPlaceholder.ReplaceAllStringFunc(
+			token,
+			func(placeholder string) string {
+				index, _ := strconv.Atoi(placeholder[1:])
+				if index < 1 || index > len(args) {
+					err = fmt.Errorf(
+						"alias '%s' expects at least %d argument(s)",
+						name, index,
+					)
+
+					return placeholder
+				}
+
+				if index > used {
+					used = index
+				}
+
+				return args[index-1]
+			},
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		expansion = append(expansion, token)
+	}
+
+	return append(expansion, args[used:]...), nil
+}
+
+func isBuiltinCommand(name string) bool {
+	for _, command := range getBuiltinCommands(usage) {
+		if command == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getBuiltinCommands returns all literal words of usage lines, like 'inbox'
+// or 'ls-reviews', so aliases can not hide them.
+func getBuiltinCommands(help string) []string {
+	commands := []string{}
+	for _, line := range reUsageLine.FindAllStringSubmatch(help, -1) {
+		line := reUsageArgument.ReplaceAllString(line[1], "")
+		for _, matches := range reUsageCommand.FindAllStringSubmatch(
+			line, -1,
+		) {
+			commands = append(commands, matches[1])
+		}
+	}
+
+	return uniqueStrings(commands)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testAliasHelp = `
+Options:
+  -u --user=<user>   Stash username.
+  -l=<count>         Number of activities to retrieve.
+  -w                 Ignore whitespaces
+`
+
+func TestExpandAlias(t *testing.T) {
+	aliases := map[string]string{
+		"r":   "$1 review -w",
+		"mrs": "myrepo ls-reviews merged",
+		"two": "$2/$1 ls",
+	}
+
+	tests := []struct {
+		cmd      []string
+		expected []string
+	}{
+		{
+			[]string{"--user", "r", "inbox"},
+			[]string{"--user", "r", "inbox"},
+		},
+		{
+			[]string{"-u", "me", "r", "proj/repo/1", "file.go"},
+			[]string{"-u", "me", "proj/repo/1", "review", "-w", "file.go"},
+		},
+		{
+			[]string{"-w", "mrs", "-d"},
+			[]string{"-w", "myrepo", "ls-reviews", "merged", "-d"},
+		},
+		{
+			[]string{"--user=me", "two", "1", "proj/repo"},
+			[]string{"--user=me", "proj/repo/1", "ls"},
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := ExpandAlias(test.cmd, aliases, testAliasHelp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected expansion of %v\n%#v\n%#v",
+				test.cmd, test.expected, actual)
+		}
+	}
+
+	_, err := ExpandAlias([]string{"r"}, aliases, testAliasHelp)
+	if err == nil {
+		t.Fatal("error expected for alias without arguments")
+	}
+}
+
+func TestGetBuiltinCommands(t *testing.T) {
+	help := `
+Usage:
+  ash [options] inbox [-d] [--news] [(reviewer|author)]
+  ash [options] search <project> [<text>] [-d]
+  ash [options] <project>/<repo>/<pr> [review] [<file-name>] [-w]
+  ash -h | --help
+`
+
+	expected := []string{"inbox", "reviewer", "author", "search", "review"}
+
+	actual := getBuiltinCommands(help)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected commands\n%#v\n%#v", expected, actual)
+	}
+
+	for _, command := range []string{"ls-projects", "create", "edit"} {
+		if !isBuiltinCommand(command) {
+			t.Fatalf("%s should be built-in command", command)
+		}
+	}
+}
@@ -15,6 +15,7 @@ type Api struct {
 	URL         string
 	Auth        gopencils.BasicAuth
 	AuthCookies []*http.Cookie
+	Cache       *ResponseCache
 }
 
 type Project struct {
@@ -22,6 +23,14 @@ type Project struct {
 	Name string
 }
 
+type User struct {
+	Id           int
+	Name         string
+	Slug         string
+	DisplayName  string
+	EmailAddress string
+}
+
 type ApiError struct {
 	Errors []struct {
 		Message string
@@ -39,7 +48,16 @@ func (u UnixTimestamp) AsTime() time.Time {
 }
 
 func (api Api) GetResource() *gopencils.Resource {
-	return gopencils.Api(fmt.Sprintf("%s/rest", api.URL), &api.Auth)
+	resource := gopencils.Api(fmt.Sprintf("%s/rest", api.URL), &api.Auth)
+	if api.Cache != nil && resource.Api.Client != nil {
+		// cache wraps transport of client, so its settings, like TLS ones,
+		// are kept
+		cache := *api.Cache
+		cache.Transport = resource.Api.Client.Transport
+		resource.Api.Client.Transport = &cache
+	}
+
+	return resource
 }
 
 func (api Api) authViaWeb() ([]*http.Cookie, error) {
@@ -122,6 +140,17 @@ func (api Api) doRequest(
 	return nil
 }
 
+func (api Api) GetUser(name string) (*User, error) {
+	user := User{}
+
+	err := api.DoGet(api.GetResource().Res("api/1.0/users").Id(name, &user))
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 func (project Project) GetRepo(name string) Repo {
 	return Repo{
 		Project: &project,
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Stale cached response will be used if Stash does not respond in time.
+const staleResponseTimeout = 10 * time.Second
+
+var rePullRequestURL = regexp.MustCompile(`^.*/pull-requests/\d+`)
+
+// ResponseCache is a http.RoundTripper, which keeps responses of GET requests
+// on disk. Responses younger than TTL are used without accessing Stash, older
+// ones are revalidated using ETag. Any modifying request (even rejected one)
+// invalidates all cached responses of the same pull request.
+type ResponseCache struct {
+	Dir       string
+	TTL       time.Duration
+	Transport http.RoundTripper
+}
+
+type cachedResponse struct {
+	URL     string
+	ETag    string
+	Fetched time.Time
+	Header  http.Header
+	Body    []byte
+}
+
+func (cache *ResponseCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		resp, err := cache.getTransport().RoundTrip(req)
+		if err == nil {
+			cache.invalidate(req)
+		}
+
+		return resp, err
+	}
+
+	cachePath := cache.getPath(req)
+	cached := cache.load(cachePath)
+
+	if cached != nil && time.Since(cached.Fetched) < cache.TTL {
+		logger.Debug("using cached response of %s", req.URL)
+		return cached.toResponse(req), nil
+	}
+
+	conditionalReq := new(http.Request)
+	*conditionalReq = *req
+	conditionalReq.Header = http.Header{}
+	for key, values := range req.Header {
+		conditionalReq.Header[key] = values
+	}
+
+	if cached != nil {
+		if cached.ETag != "" {
+			conditionalReq.Header.Set("If-None-Match", cached.ETag)
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), staleResponseTimeout)
+		defer cancel()
+
+		conditionalReq = conditionalReq.WithContext(ctx)
+	}
+
+	resp, err := cache.getTransport().RoundTrip(conditionalReq)
+	if err == nil {
+		var body []byte
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err == nil {
+			return cache.handleResponse(req, resp, body, cached, cachePath), nil
+		}
+	}
+
+	if cached == nil {
+		return nil, err
+	}
+
+	logger.Warning(
+		"can not access Stash (%s), using response cached at %s",
+		err.Error(), cached.Fetched.Format(time.Stamp),
+	)
+
+	return cached.toResponse(req), nil
+}
+
+func (cache *ResponseCache) handleResponse(
+	req *http.Request, resp *http.Response, body []byte,
+	cached *cachedResponse, cachePath string,
+) *http.Response {
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		logger.Debug("cached response of %s is not modified", req.URL)
+		cached.Fetched = time.Now()
+		cache.save(cachePath, cached)
+
+		return cached.toResponse(req)
+
+	case resp.StatusCode == http.StatusOK:
+		cache.save(cachePath, &cachedResponse{
+			URL:     req.URL.String(),
+			ETag:    resp.Header.Get("ETag"),
+			Fetched: time.Now(),
+			Header:  resp.Header,
+			Body:    body,
+		})
+	}
+
+	return resp
+}
+
+func (cache *ResponseCache) getTransport() http.RoundTripper {
+	if cache.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return cache.Transport
+}
+
+// getGroupDir returns directory for all responses of pull request, so they
+// can be invalidated at once.
+func (cache *ResponseCache) getGroupDir(req *http.Request) string {
+	user, _, _ := req.BasicAuth()
+
+	group := rePullRequestURL.FindString(req.URL.String())
+	if group == "" {
+		group = req.URL.String()
+	}
+
+	return filepath.Join(cache.Dir, hash(user+" "+group))
+}
+
+func (cache *ResponseCache) getPath(req *http.Request) string {
+	return filepath.Join(
+		cache.getGroupDir(req), hash(req.URL.String())+".json",
+	)
+}
+
+func (cache *ResponseCache) invalidate(req *http.Request) {
+	logger.Debug("invalidating cached responses of %s", req.URL)
+	os.RemoveAll(cache.getGroupDir(req))
+}
+
+func (cache *ResponseCache) load(path string) *cachedResponse {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	cached := &cachedResponse{}
+	err = json.Unmarshal(data, cached)
+	if err != nil {
+		logger.Warning("can not read cached response %s: %s", path, err)
+		return nil
+	}
+
+	return cached
+}
+
+func (cache *ResponseCache) save(path string, cached *cachedResponse) {
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+
+	if err == nil {
+		err = ioutil.WriteFile(path, data, 0600)
+	}
+
+	if err != nil {
+		logger.Warning("can not cache response: %s", err.Error())
+	}
+}
+
+func (cached *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cached.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+func hash(value string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(value)))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("hello"))
+		}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir(os.TempDir(), "ash-cache-test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cache := &ResponseCache{Dir: dir, TTL: time.Hour}
+	client := &http.Client{Transport: cache}
+
+	get := func() string {
+		resp, err := client.Get(server.URL + "/pull-requests/1/diff")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status code: %d", resp.StatusCode)
+		}
+
+		return string(body)
+	}
+
+	tests := []struct {
+		prepare  func()
+		requests int
+	}{
+		{func() {}, 1},
+		{func() {}, 1},
+		{func() { cache.TTL = 0 }, 2},
+		{func() { server.Close() }, 2},
+	}
+
+	for _, test := range tests {
+		test.prepare()
+
+		if body := get(); body != "hello" {
+			t.Fatalf("unexpected body: %q", body)
+		}
+
+		if requests != test.requests {
+			t.Fatalf("unexpected number of requests: %d instead of %d",
+				requests, test.requests)
+		}
+	}
+}
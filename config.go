@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	reProfileSection = regexp.MustCompile(`^\[([^\]]+)\]$`)
+	reConfigSection  = regexp.MustCompile(`^\[(profile|project|repo) "([^"]+)"\]$`)
+	reAliasSection   = regexp.MustCompile(`^\[alias\]$`)
+	reConfigKey      = regexp.MustCompile(`^([a-z][a-z0-9-]*)\s*=\s*(.*)$`)
+)
+
+type configKind int
+
+const (
+	configString configKind = iota
+	configBool
+	configNumber
+	configDuration
+	configList
+)
+
+// configKey describes typed key of structured config. Scoped keys can be
+// overridden in project and repo sections.
+type configKey struct {
+	flag   string
+	kind   configKind
+	scoped bool
+}
+
+var configKeys = map[string]configKey{
+	"user":              {"--user", configString, false},
+	"pass":              {"--pass", configString, false},
+	"url":               {"--url", configString, false},
+	"project":           {"--project", configString, false},
+	"profile":           {"--profile", configString, false},
+	"editor":            {"-e", configString, true},
+	"ignore-whitespace": {"-w", configBool, true},
+	"context":           {"--context", configNumber, true},
+	"wrap":              {"--wrap", configNumber, true},
+	"reviewers":         {"", configList, true},
+	"template":          {"", configString, true},
+	"activities-limit":  {"-l", configNumber, false},
+	"max-removals":      {"--max-removals", configNumber, false},
+	"cache-ttl":         {"--cache-ttl", configDuration, false},
+	"no-cache":          {"--no-cache", configBool, false},
+	"no-color":          {"--no-color", configBool, false},
+	"debug":             {"--debug", configNumber, false},
+}
+
+// configEntry is an option along with its values, e.g. ["--user", "me"].
+type configEntry []string
+
+func (entry configEntry) Name() string {
+	return strings.SplitN(entry[0], "=", 2)[0]
+}
+
+func (entry configEntry) Value() string {
+	parts := strings.SplitN(entry[0], "=", 2)
+	if len(parts) == 2 {
+		return parts[1]
+	}
+
+	if len(entry) > 1 {
+		return entry[1]
+	}
+
+	return ""
+}
+
+type Config struct {
+	Global   []configEntry
+	Profiles map[string][]configEntry
+
+	// Scopes holds values of scoped keys for '<project>' and
+	// '<project>/<repo>' sections of structured config.
+	Scopes map[string]map[string]string
+
+	// Aliases maps alias name to the arguments it is expanded to.
+	Aliases map[string]string
+}
+
+// LoadConfig parses config either in the structured format or in the legacy
+// command line format, depending on the first meaningful line. Keys of
+// structured config start with a letter, so options like '--user=me' are
+// never taken for them.
+func LoadConfig(data string) (Config, error) {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if reConfigSection.MatchString(line) || reConfigKey.MatchString(line) ||
+			reAliasSection.MatchString(line) {
+			return ParseStructuredConfig(data)
+		}
+
+		break
+	}
+
+	return ParseConfig(data), nil
+}
+
+// ParseStructuredConfig reads INI-like config:
+//
+//	user = me
+//	editor = vim
+//
+//	[profile "partner"]
+//	url = https://bitbucket.partner.com
+//
+//	[repo "PROJ/repo"]
+//	ignore-whitespace = true
+func ParseStructuredConfig(data string) (Config, error) {
+	config := Config{
+		Profiles: map[string][]configEntry{},
+		Scopes:   map[string]map[string]string{},
+		Aliases:  map[string]string{},
+	}
+
+	section, name := "", ""
+	for number, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if matches := reConfigSection.FindStringSubmatch(line); matches != nil {
+			section, name = matches[1], matches[2]
+
+			switch section {
+			case "profile":
+				config.Profiles[name] = config.Profiles[name]
+			case "project", "repo":
+				invalid := section == "project" && strings.Contains(name, "/") ||
+					section == "repo" && strings.Count(name, "/") != 1
+				if invalid {
+					return config, fmt.Errorf(
+						"line %d: %s section name '%s' is invalid",
+						number+1, section, name,
+					)
+				}
+
+				name = getScopeKey(name)
+				if config.Scopes[name] == nil {
+					config.Scopes[name] = map[string]string{}
+				}
+			}
+
+			continue
+		}
+
+		if reAliasSection.MatchString(line) {
+			section, name = "alias", ""
+			continue
+		}
+
+		matches := reConfigKey.FindStringSubmatch(line)
+		if matches == nil {
+			return config, fmt.Errorf(
+				"line %d: expected 'key = value', got '%s'", number+1, line,
+			)
+		}
+
+		key, value := matches[1], strings.Trim(matches[2], `"`)
+
+		if section == "alias" {
+			if isBuiltinCommand(key) {
+				return config, fmt.Errorf(
+					"line %d: alias '%s' hides built-in command",
+					number+1, key,
+				)
+			}
+
+			config.Aliases[key] = value
+			continue
+		}
+
+		err := validateConfigValue(key, value, section == "project" ||
+			section == "repo")
+		if err != nil {
+			return config, fmt.Errorf("line %d: %s", number+1, err.Error())
+		}
+
+		switch section {
+		case "project", "repo":
+			config.Scopes[name][key] = value
+		case "profile":
+			config.Profiles[name] = appendConfigValue(
+				config.Profiles[name], key, value,
+			)
+		default:
+			config.Global = appendConfigValue(config.Global, key, value)
+		}
+	}
+
+	return config, nil
+}
+
+func validateConfigValue(key string, value string, scoped bool) error {
+	spec, ok := configKeys[key]
+	if !ok {
+		return fmt.Errorf("unknown key '%s'", key)
+	}
+
+	if scoped && !spec.scoped {
+		return fmt.Errorf("key '%s' can not be set per project or repo", key)
+	}
+
+	var err error
+	switch spec.kind {
+	case configBool:
+		_, err = strconv.ParseBool(value)
+	case configNumber:
+		_, err = strconv.Atoi(value)
+	case configDuration:
+		_, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid value of '%s': %s", key, value)
+	}
+
+	return nil
+}
+
+// appendConfigValue converts typed value into command line arguments.
+// Disabled flags and keys without flag (like reviewers) are not passed to
+// the command line.
+func appendConfigValue(
+	entries []configEntry, key string, value string,
+) []configEntry {
+	spec := configKeys[key]
+	if spec.flag == "" {
+		return entries
+	}
+
+	if spec.kind == configBool {
+		if enabled, _ := strconv.ParseBool(value); enabled {
+			entries = append(entries, configEntry{spec.flag})
+		}
+
+		return entries
+	}
+
+	return append(entries, configEntry{spec.flag, value})
+}
+
+// GetScoped returns values of scoped keys for the given repo, where repo
+// section values take priority over project section ones. Project is
+// specified by its key, like 'PROJ' or '~user' for personal repos.
+func (config Config) GetScoped(project string, repo string) map[string]string {
+	values := map[string]string{}
+	for _, scope := range []string{project, project + "/" + repo} {
+		for key, value := range config.Scopes[getScopeKey(scope)] {
+			values[key] = value
+		}
+	}
+
+	return values
+}
+
+// getScopeKey returns canonical name of project or repo section, since
+// project keys and repo slugs are case-insensitive in Stash.
+func getScopeKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// GetReviewers returns default reviewers for the given repo.
+func (config Config) GetReviewers(project string, repo string) []string {
+	return parseList(config.GetScoped(project, repo)["reviewers"])
+}
+
+// parseList splits comma-separated list, skipping empty items.
+func parseList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// ParseConfig reads config in the command line format: every non-empty line
+// is a single argument. Lines like '[name]' start named profile, which
+// arguments override global ones when profile is selected.
+func ParseConfig(data string) Config {
+	config := Config{
+		Profiles: map[string][]configEntry{},
+	}
+
+	profile := ""
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if matches := reProfileSection.FindStringSubmatch(line); matches != nil {
+			profile = matches[1]
+			config.Profiles[profile] = config.Profiles[profile]
+			continue
+		}
+
+		entries := config.Global
+		if profile != "" {
+			entries = config.Profiles[profile]
+		}
+
+		if strings.HasPrefix(line, "-") || len(entries) == 0 {
+			entries = append(entries, configEntry{line})
+		} else {
+			last := len(entries) - 1
+			entries[last] = append(entries[last], line)
+		}
+
+		if profile != "" {
+			config.Profiles[profile] = entries
+		} else {
+			config.Global = entries
+		}
+	}
+
+	return config
+}
+
+// GetArgs returns global arguments, overridden by arguments of specified
+// profile. Profile selection itself is omitted, since it is already done.
+func (config Config) GetArgs(profile string) []string {
+	overrides := map[string]bool{"--profile": true}
+	for _, entry := range config.Profiles[profile] {
+		overrides[entry.Name()] = true
+	}
+
+	args := []string{}
+	for _, entry := range config.Global {
+		if !overrides[entry.Name()] {
+			args = append(args, entry...)
+		}
+	}
+
+	for _, entry := range config.Profiles[profile] {
+		if entry.Name() != "--profile" {
+			args = append(args, entry...)
+		}
+	}
+
+	return args
+}
+
+// SelectProfile returns profile name either specified explicitly via
+// --profile or found by the host of pull request URL given in args.
+func (config Config) SelectProfile(args []string) string {
+	for _, source := range [][]string{args, config.getGlobalArgs()} {
+		for i, arg := range source {
+			switch {
+			case strings.HasPrefix(arg, "--profile="):
+				return strings.TrimPrefix(arg, "--profile=")
+			case arg == "--profile" && i+1 < len(source):
+				return source[i+1]
+			}
+		}
+	}
+
+	for _, arg := range args {
+		if reStashURL.MatchString(arg) {
+			return config.FindProfileByURL(arg)
+		}
+	}
+
+	return ""
+}
+
+func (config Config) getGlobalArgs() []string {
+	args := []string{}
+	for _, entry := range config.Global {
+		args = append(args, entry...)
+	}
+
+	return args
+}
+
+// FindProfileByURL returns name of the profile, which --url is the longest
+// prefix of given URL. Hosts are compared case-insensitively and scheme is
+// ignored; if several profiles have the same --url, first by name is used.
+func (config Config) FindProfileByURL(uri string) string {
+	target := normalizeProfileURL(uri)
+	if target == "" {
+		return ""
+	}
+
+	names := []string{}
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	found, length := "", 0
+	for _, name := range names {
+		for _, entry := range config.Profiles[name] {
+			if entry.Name() != "--url" {
+				continue
+			}
+
+			prefix := normalizeProfileURL(entry.Value())
+			if prefix == "" || len(prefix) <= length {
+				continue
+			}
+
+			if target == prefix || strings.HasPrefix(target, prefix+"/") {
+				found, length = name, len(prefix)
+			}
+		}
+	}
+
+	return found
+}
+
+// normalizeProfileURL returns URL without scheme and trailing slash, with
+// host in lower case.
+func normalizeProfileURL(uri string) string {
+	if !strings.Contains(uri, "://") {
+		uri = "http://" + uri
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Host) + strings.TrimSuffix(parsed.Path, "/")
+}
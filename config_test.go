@@ -0,0 +1,239 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testConfig = `
+--user
+  me
+--url
+  http://stash.local/
+
+[partner]
+--url
+  https://bitbucket.partner.com
+--user
+  partner-me
+`
+
+func TestConfigGetArgs(t *testing.T) {
+	config := ParseConfig(testConfig)
+
+	tests := []struct {
+		profile  string
+		expected []string
+	}{
+		{
+			"",
+			[]string{"--user", "me", "--url", "http://stash.local/"},
+		},
+		{
+			"partner",
+			[]string{
+				"--url", "https://bitbucket.partner.com",
+				"--user", "partner-me",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		actual := config.GetArgs(test.profile)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected args for profile '%s'\n%#v\n%#v",
+				test.profile, test.expected, actual)
+		}
+	}
+}
+
+func TestConfigSelectProfile(t *testing.T) {
+	config := ParseConfig(testConfig)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"inbox"}, ""},
+		{[]string{"--profile=partner", "inbox"}, "partner"},
+		{[]string{"--profile", "other", "inbox"}, "other"},
+		{
+			[]string{
+				"https://bitbucket.partner.com/projects/p/repos/r/pull-requests/1",
+			},
+			"partner",
+		},
+		{
+			[]string{
+				"http://stash.local/projects/p/repos/r/pull-requests/1",
+			},
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		actual := config.SelectProfile(test.args)
+		if actual != test.expected {
+			t.Fatalf("unexpected profile for %v: '%s' instead of '%s'",
+				test.args, actual, test.expected)
+		}
+	}
+}
+
+func TestFindProfileByURL(t *testing.T) {
+	config := ParseConfig(`
+[root]
+--url
+  http://stash.local
+[b-team]
+--url
+  https://Stash.local/team/
+[a-team]
+--url
+  http://stash.local/team
+`)
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://stash.local/projects/p/repos/r/pull-requests/1", "root"},
+		{"http://stash.local/team/projects/p/repos/r/pull-requests/1", "a-team"},
+		{"http://stash.local/teams/projects/p/repos/r/pull-requests/1", "root"},
+		{"http://other.local/projects/p/repos/r/pull-requests/1", ""},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 10; i++ {
+			actual := config.FindProfileByURL(test.url)
+			if actual != test.expected {
+				t.Fatalf("unexpected profile for %s: '%s' instead of '%s'",
+					test.url, actual, test.expected)
+			}
+		}
+	}
+}
+
+const testStructuredConfig = `
+# comment
+user = me
+url = "http://stash.local/"
+ignore-whitespace = false
+no-cache = true
+
+[profile "partner"]
+url = https://bitbucket.partner.com
+
+[project "PROJ"]
+editor = vim
+reviewers = alice, bob
+
+[repo "PROJ/repo"]
+ignore-whitespace = true
+reviewers = carol
+
+[alias]
+r = $1 review -w
+`
+
+func TestLoadStructuredConfig(t *testing.T) {
+	config, err := LoadConfig(testStructuredConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"--user", "me", "--url", "http://stash.local/", "--no-cache",
+	}
+
+	if actual := config.GetArgs(""); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected args\n%#v\n%#v", expected, actual)
+	}
+
+	profile := config.SelectProfile([]string{
+		"https://bitbucket.partner.com/projects/p/repos/r/pull-requests/1",
+	})
+	if profile != "partner" {
+		t.Fatalf("unexpected profile: '%s'", profile)
+	}
+
+	scoped := config.GetScoped("proj", "Repo")
+	if scoped["editor"] != "vim" || scoped["ignore-whitespace"] != "true" {
+		t.Fatalf("unexpected scoped values: %#v", scoped)
+	}
+
+	if config.Aliases["r"] != "$1 review -w" {
+		t.Fatalf("unexpected aliases: %#v", config.Aliases)
+	}
+
+	reviewers := config.GetReviewers("PROJ", "other")
+	if !reflect.DeepEqual([]string{"alice", "bob"}, reviewers) {
+		t.Fatalf("unexpected reviewers: %#v", reviewers)
+	}
+}
+
+func TestLoadStructuredConfigErrors(t *testing.T) {
+	tests := []string{
+		"user = me\nunknown = value",
+		"context = many",
+		"[project \"PROJ\"]\nuser = me",
+		"[repo \"repo\"]\neditor = vim",
+		"user = me\n--pass",
+		"[alias]\ninbox = inbox reviewer",
+	}
+
+	for _, test := range tests {
+		if _, err := LoadConfig(test); err == nil {
+			t.Fatalf("error expected for config:\n%s", test)
+		}
+	}
+}
+
+func TestLoadLegacyConfigWithValues(t *testing.T) {
+	config, err := LoadConfig("--user=me\n--url=http://stash.local\n-e=vim\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"--user=me", "--url=http://stash.local", "-e=vim"}
+	if actual := config.GetArgs(""); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected args\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestLoadLegacyConfig(t *testing.T) {
+	config, err := LoadConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Profiles["partner"]) != 2 {
+		t.Fatalf("legacy config is not parsed: %#v", config)
+	}
+}
+
+func TestIsFlagInCmdLine(t *testing.T) {
+	tests := []struct {
+		flag     string
+		cmdLine  []string
+		expected bool
+	}{
+		{"-w", []string{"proj/repo/1", "review", "-w"}, true},
+		{"-w", []string{"-iw", "proj/repo/1"}, true},
+		{"-i", []string{"-wi"}, true},
+		{"-w", []string{"-i", "proj/repo/1"}, false},
+		{"-i", []string{"-evim"}, false},
+		{"-w", []string{"-e", "-w"}, false},
+		{"-w", []string{"-e", "vim", "-w"}, true},
+		{"--wrap", []string{"--wrap=80"}, true},
+		{"--wrap", []string{"-w"}, false},
+	}
+
+	for _, test := range tests {
+		actual := isFlagInCmdLine(test.flag, test.cmdLine)
+		if actual != test.expected {
+			t.Fatalf("unexpected result for %s in %q: %v",
+				test.flag, test.cmdLine, actual)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/seletskiy/godiff"
+)
+
+const conflictHelpText = "### Comment was changed by someone else while you were editing it.\n" +
+	"### Resolve the conflict between your and their versions below, then\n" +
+	"### save the file. Lines beginning with ### will be ignored.\n"
+
+var reConflictMarker = regexp.MustCompile(`(?m)^(<<<<<<<|=======|>>>>>>>)`)
+
+var reIgnoredLine = regexp.MustCompile(`(?m)^###.*\n?`)
+
+// resolveConflict retries change, which was rejected because comment was
+// changed remotely. Texts are merged automatically if possible, otherwise
+// user is asked to resolve conflict in editor.
+func resolveConflict(
+	pr PullRequest, change ReviewChange, remote *godiff.Comment,
+	editor string,
+) error {
+	switch c := change.(type) {
+	case CommentModified:
+		text, merged := MergeText(
+			normalizeCommentText(c.original.Text, c.wrapWidth),
+			c.comment.Text,
+			normalizeCommentText(remote.Text, c.wrapWidth),
+		)
+		if !merged {
+			var err error
+			text, err = editConflict(editor, c.comment.Text, remote)
+			if err != nil {
+				return err
+			}
+		}
+
+		resolved := *c.comment
+		resolved.Text = text
+		resolved.Version = remote.Version
+
+		return pr.ApplyChange(CommentModified{&resolved, remote, c.wrapWidth})
+
+	case CommentRemoved:
+		if len(remote.Comments) > 0 {
+			return fmt.Errorf(
+				"comment <%d> has replies and can not be deleted", remote.Id,
+			)
+		}
+
+		if trimCommentSpaces(remote.Text) != trimCommentSpaces(c.comment.Text) {
+			return fmt.Errorf(
+				"comment <%d> was modified by someone else, not deleting it",
+				remote.Id,
+			)
+		}
+
+		resolved := *c.comment
+		resolved.Version = remote.Version
+
+		return pr.ApplyChange(CommentRemoved{&resolved})
+	}
+
+	return commentConflict{remote}
+}
+
+func editConflict(
+	editor string, ours string, remote *godiff.Comment,
+) (string, error) {
+	if editor == "" {
+		return "", fmt.Errorf(
+			"comment <%d> has conflicting changes and editor is not set",
+			remote.Id,
+		)
+	}
+
+	// conflict file is kept out of work dir to not lose it on error
+	conflictFile, err := ioutil.TempFile(
+		os.TempDir(), fmt.Sprintf("ash.conflict-%d.", remote.Id),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	conflictPath := conflictFile.Name()
+
+	_, err = fmt.Fprintf(conflictFile,
+		"%s<<<<<<< yours\n%s\n=======\n%s\n>>>>>>> theirs (version %d)\n",
+		conflictHelpText, ours, remote.Text, remote.Version,
+	)
+
+	conflictFile.Close()
+
+	if err != nil {
+		return "", err
+	}
+
+	err = runEditor(editor, conflictPath)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := ioutil.ReadFile(conflictPath)
+	if err != nil {
+		return "", err
+	}
+
+	text := reIgnoredLine.ReplaceAllString(string(resolved), "")
+
+	if reConflictMarker.MatchString(text) {
+		return "", fmt.Errorf(
+			"conflict markers are left in %s, comment is not modified",
+			conflictPath,
+		)
+	}
+
+	os.Remove(conflictPath)
+
+	return strings.TrimSpace(text), nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultReviewersCondition is a condition of Bitbucket default reviewers
+// plugin: reviewers are added to pull requests from source to target refs
+// matched by corresponding matchers.
+type DefaultReviewersCondition struct {
+	Id               int64
+	SourceRefMatcher RefMatcher
+	TargetRefMatcher RefMatcher
+	Reviewers        []User
+}
+
+type RefMatcher struct {
+	Id        string
+	DisplayId string
+	Type      struct {
+		Id string
+	}
+}
+
+// Match returns true if ref is matched. Branching model matchers are not
+// supported, because model is not available via API, so they never match.
+func (matcher RefMatcher) Match(ref string) bool {
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+
+	switch matcher.Type.Id {
+	case "ANY_REF":
+		return true
+	case "BRANCH":
+		return matcher.Id == ref || matcher.DisplayId == branch
+	case "PATTERN":
+		pattern := regexp.QuoteMeta(matcher.Id)
+		pattern = strings.Replace(pattern, `\*`, ".*", -1)
+
+		matched, _ := regexp.MatchString("^(refs/heads/)?"+pattern+"$", ref)
+		return matched
+	}
+
+	return false
+}
+
+func (project Project) Key() string {
+	if strings.HasPrefix(project.Name, "users/") {
+		return "~" + strings.TrimPrefix(project.Name, "users/")
+	}
+
+	return strings.TrimPrefix(project.Name, "projects/")
+}
+
+func (repo *Repo) GetDefaultReviewersConditions() (
+	[]DefaultReviewersCondition, error,
+) {
+	conditions := []DefaultReviewersCondition{}
+
+	err := repo.DoGet(
+		repo.GetResource().Res("default-reviewers/1.0").
+			Res(repo.Project.Name).Res("repos").Res(repo.Name).
+			Res("conditions", &conditions),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return conditions, nil
+}
+
+// GetDefaultReviewers returns names of reviewers, specified in config for the
+// repo along with ones from default reviewers conditions matching refs.
+func (repo *Repo) GetDefaultReviewers(
+	config Config, fromRef string, toRef string,
+) []string {
+	conditions, err := repo.GetDefaultReviewersConditions()
+	if err != nil {
+		logger.Warning(
+			"can not get default reviewers conditions: %s", err.Error(),
+		)
+	}
+
+	return MatchDefaultReviewers(
+		config.GetReviewers(repo.Project.Key(), repo.Name),
+		conditions, fromRef, toRef,
+	)
+}
+
+func MatchDefaultReviewers(
+	reviewers []string, conditions []DefaultReviewersCondition,
+	fromRef string, toRef string,
+) []string {
+	for _, condition := range conditions {
+		if !condition.SourceRefMatcher.Match(fromRef) ||
+			!condition.TargetRefMatcher.Match(toRef) {
+			continue
+		}
+
+		for _, user := range condition.Reviewers {
+			reviewers = append(reviewers, user.Name)
+		}
+	}
+
+	return uniqueStrings(reviewers)
+}
+
+func (repo *Repo) GetDefaultBranch() (string, error) {
+	branch := struct {
+		Id string
+	}{}
+
+	err := repo.DoGet(repo.Resource.Res("branches").Res("default", &branch))
+	if err != nil {
+		return "", err
+	}
+
+	return branch.Id, nil
+}
+
+func (repo *Repo) CreatePullRequest(
+	title string, description string,
+	fromRef string, toRef string,
+	reviewers []string,
+) (*PullRequest, error) {
+	ref := func(id string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id,
+			"repository": map[string]interface{}{
+				"slug": repo.Name,
+				"project": map[string]interface{}{
+					"key": repo.Project.Key(),
+				},
+			},
+		}
+	}
+
+	payload := map[string]interface{}{
+		"title":       title,
+		"description": description,
+		"fromRef":     ref(fromRef),
+		"toRef":       ref(toRef),
+		"reviewers":   getReviewersPayload(reviewers),
+	}
+
+	result := PullRequest{}
+	err := repo.DoPost(repo.Resource.Res("pull-requests", &result), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	created := repo.GetPullRequest(result.Id)
+
+	return &created, nil
+}
+
+// Update changes title, description and reviewers of pull request. Stash
+// replaces all reviewers with specified ones, so existing reviewers should
+// be passed too.
+func (pr *PullRequest) Update(
+	version int64, title string, description string, reviewers []string,
+) error {
+	pr.Resource.Response = &PullRequestInfo{}
+
+	return pr.DoPut(
+		pr.Resource,
+		getUpdatePayload(version, title, description, reviewers),
+	)
+}
+
+func getUpdatePayload(
+	version int64, title string, description string, reviewers []string,
+) map[string]interface{} {
+	return map[string]interface{}{
+		"version":     version,
+		"title":       title,
+		"description": description,
+		"reviewers":   getReviewersPayload(reviewers),
+	}
+}
+
+func getReviewersPayload(reviewers []string) []interface{} {
+	payload := []interface{}{}
+	for _, name := range reviewers {
+		payload = append(payload, map[string]interface{}{
+			"user": map[string]interface{}{"name": name},
+		})
+	}
+
+	return payload
+}
+
+// ReadTemplate returns description template for the repo, if it is
+// specified in config.
+func ReadTemplate(config Config, project string, repo string) string {
+	path := config.GetScoped(project, repo)["template"]
+	if path == "" {
+		return ""
+	}
+
+	if strings.HasPrefix(path, "~/") {
+		path = os.Getenv("HOME") + path[1:]
+	}
+
+	template, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Warning("can not read template: %s", err.Error())
+		return ""
+	}
+
+	return string(template)
+}
+
+// ParsePullRequestMessage splits edited message into title (first line) and
+// description (rest of lines). Lines beginning with '###' are ignored.
+func ParsePullRequestMessage(message string) (string, string) {
+	lines := []string{}
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "###") {
+			lines = append(lines, line)
+		}
+	}
+
+	message = strings.TrimSpace(strings.Join(lines, "\n"))
+	parts := strings.SplitN(message, "\n", 2)
+
+	title := strings.TrimSpace(parts[0])
+	description := ""
+	if len(parts) == 2 {
+		description = strings.TrimSpace(parts[1])
+	}
+
+	return title, description
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// withoutUser returns names except specified user, e.g. to exclude author of
+// pull request from its reviewers, since Stash rejects such reviewers.
+func withoutUser(names []string, user string) []string {
+	result := []string{}
+	for _, name := range names {
+		if !strings.EqualFold(name, user) {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
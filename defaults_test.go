@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testDefaultReviewersConditions = `[
+	{
+		"id": 1,
+		"sourceRefMatcher": {"id": "ANY_REF_MATCHER_ID", "type": {"id": "ANY_REF"}},
+		"targetRefMatcher": {
+			"id": "refs/heads/master", "displayId": "master",
+			"type": {"id": "BRANCH"}
+		},
+		"reviewers": [{"name": "alice"}]
+	},
+	{
+		"id": 2,
+		"sourceRefMatcher": {"id": "feature/*", "type": {"id": "PATTERN"}},
+		"targetRefMatcher": {"id": "ANY_REF_MATCHER_ID", "type": {"id": "ANY_REF"}},
+		"reviewers": [{"name": "bob"}, {"name": "alice"}]
+	}
+]`
+
+func TestMatchDefaultReviewers(t *testing.T) {
+	conditions := []DefaultReviewersCondition{}
+	err := json.Unmarshal([]byte(testDefaultReviewersConditions), &conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from     string
+		to       string
+		expected []string
+	}{
+		{"refs/heads/fix", "refs/heads/master", []string{"carol", "alice"}},
+		{
+			"refs/heads/feature/x", "refs/heads/dev",
+			[]string{"carol", "bob", "alice"},
+		},
+		{"refs/heads/fix", "refs/heads/dev", []string{"carol"}},
+	}
+
+	for _, test := range tests {
+		actual := MatchDefaultReviewers(
+			[]string{"carol"}, conditions, test.from, test.to,
+		)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected reviewers for %s -> %s\n%#v\n%#v",
+				test.from, test.to, test.expected, actual)
+		}
+	}
+}
+
+func TestParsePullRequestMessage(t *testing.T) {
+	title, description := ParsePullRequestMessage(
+		"\nFix everything\n\nIt was broken.\n\n### comment\n",
+	)
+
+	if title != "Fix everything" || description != "It was broken." {
+		t.Fatalf("unexpected title and description: %q %q",
+			title, description)
+	}
+}
+
+func TestReviewersWithoutAuthor(t *testing.T) {
+	actual := withoutUser(parseList(" alice,,Bob , carol,"), "bob")
+
+	expected := []string{"alice", "carol"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected reviewers\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestUpdatePayloadKeepsReviewers(t *testing.T) {
+	info := PullRequestInfo{}
+	err := json.Unmarshal([]byte(`{
+		"version": 3, "title": "Fix", "description": "old",
+		"reviewers": [
+			{"approved": true, "user": {"name": "alice"}},
+			{"user": {"name": "bob"}}
+		]
+	}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"version":     int64(3),
+		"title":       "Fix",
+		"description": "new",
+		"reviewers": []interface{}{
+			map[string]interface{}{
+				"user": map[string]interface{}{"name": "alice"},
+			},
+			map[string]interface{}{
+				"user": map[string]interface{}{"name": "bob"},
+			},
+		},
+	}
+
+	actual := getUpdatePayload(
+		info.Version, info.Title, "new", info.GetReviewerNames(),
+	)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected payload\n%#v\n%#v", expected, actual)
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/kovetskiy/hierr"
+	"github.com/seletskiy/godiff"
 )
 
 type ReviewFiles []ReviewFile
@@ -21,6 +23,9 @@ type ReviewFile struct {
 	SrcExec    bool
 	DstExec    bool
 	Unchanged  int
+	Added      int
+	Removed    int
+	Comments   int
 }
 
 func (rf *ReviewFiles) UnmarshalJSON(data []byte) error {
@@ -57,78 +62,225 @@ func (rf *ReviewFiles) UnmarshalJSON(data []byte) error {
 			ChangeType: change.Type,
 			SrcExec:    change.SrcExecutable,
 			DstExec:    change.Executable,
+			Unchanged:  change.PercentUnchanged,
 		})
 	}
 
 	return nil
 }
 
-type ErrorPointer struct {
-	Pointer interface{}
+// SetStats fills added and removed lines counts and number of comments of
+// every file from given pull request diff.
+func (rf ReviewFiles) SetStats(changeset *godiff.Changeset) {
+	for i := range rf {
+		rf[i].Added, rf[i].Removed, rf[i].Comments = 0, 0, 0
+	}
+
+	for _, diff := range changeset.Diffs {
+		path := diff.Destination.ToString
+		if path == "" {
+			path = diff.Source.ToString
+		}
+
+		var file *ReviewFile
+		for i := range rf {
+			if rf[i].DstPath == path {
+				file = &rf[i]
+				break
+			}
+		}
+
+		if file == nil {
+			logger.Debug("no file in changes list for diff '%s'", path)
+			continue
+		}
+
+		diff.ForEachLine(
+			func(
+				_ *godiff.Diff, _ *godiff.Hunk,
+				segment *godiff.Segment, _ *godiff.Line,
+			) error {
+				switch segment.Type {
+				case godiff.SegmentTypeAdded:
+					file.Added++
+				case godiff.SegmentTypeRemoved:
+					file.Removed++
+				}
+
+				return nil
+			})
+
+		file.Comments += countComments(diff.LineComments) +
+			countComments(diff.FileComments)
+	}
+}
+
+func countComments(comments godiff.CommentsTree) int {
+	count := 0
+	for _, comment := range comments {
+		count += 1 + countComments(comment.Comments)
+	}
+
+	return count
 }
 
-func (err ErrorPointer) String() string {
-	return (*err.Pointer.(*error)).Error()
+type fileTreeNode struct {
+	name  string
+	dirs  []*fileTreeNode
+	files []ReviewFile
 }
 
 func (rf ReviewFiles) String() string {
-	rootPrefix := ""
-	entryRoot := fmt.Errorf(".")
-	entries := map[string]*error{
-		rootPrefix: &entryRoot,
+	return buildFileTree(rf).toError().Error()
+}
+
+func (file ReviewFile) String() string {
+	name := file.Name
+
+	switch file.ChangeType {
+	case "ADD":
+		name = "A " + name
+	case "MODIFY":
+		name = "M " + name
+	case "DELETE":
+		name = "D " + name
+	case "MOVE":
+		name = "R " + file.SrcPath + " -> " + file.DstPath
+	case "COPY":
+		name = "C " + file.SrcPath + " -> " + file.DstPath
 	}
 
-	for _, file := range rf {
+	details := []string{}
+	if file.Added > 0 || file.Removed > 0 {
+		details = append(details, fmt.Sprintf("+%d -%d", file.Added, file.Removed))
+	}
 
-		dirname := file.Parent
-		for {
-			dirname = filepath.Dir(dirname)
+	if file.ChangeType == "MOVE" && file.Unchanged > 0 && file.Unchanged < 100 {
+		details = append(details, fmt.Sprintf("%d%% unchanged", file.Unchanged))
+	}
 
-			if _, ok := entries[dirname]; ok {
-				break
-			}
+	if file.Comments > 0 {
+		details = append(details, fmt.Sprintf("%d comment(s)", file.Comments))
+	}
 
-			if dirname == "." {
-				dirname = rootPrefix
-				break
+	if file.SrcExec != file.DstExec {
+		if file.DstExec {
+			details = append(details, "+x")
+		} else {
+			details = append(details, "-x")
+		}
+	}
+
+	if len(details) > 0 {
+		name += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return name
+}
+
+// WithPath returns file named by its full path, so it can be listed out of
+// the files tree.
+func (file ReviewFile) WithPath() ReviewFile {
+	file.Name = file.DstPath
+
+	return file
+}
+
+// buildFileTree groups files by directories. Directories without files and
+// with only one subdirectory are joined with it, like 'src/main/java'.
+func buildFileTree(files ReviewFiles) *fileTreeNode {
+	root := &fileTreeNode{name: "."}
+
+	for _, file := range files {
+		node := root
+		if file.Parent != "" {
+			for _, name := range strings.Split(file.Parent, "/") {
+				node = node.getDir(name)
 			}
 		}
 
-		if _, ok := entries[file.Parent]; !ok {
-			parent := fmt.Errorf(
-				strings.TrimPrefix(file.Parent, dirname+"/"),
-			)
-			entries[file.Parent] = &parent
+		node.files = append(node.files, file)
+	}
 
-			newRoot := hierr.Push(
-				*entries[dirname],
-				ErrorPointer{&parent},
-			)
-			*entries[dirname] = newRoot
+	root.compact()
+	root.sort()
 
+	return root
+}
+
+func (node *fileTreeNode) getDir(name string) *fileTreeNode {
+	for _, dir := range node.dirs {
+		if dir.name == name {
+			return dir
 		}
+	}
+
+	dir := &fileTreeNode{name: name}
+	node.dirs = append(node.dirs, dir)
 
-		filename := file.Name
-
-		switch file.ChangeType {
-		case "ADD":
-			filename = "A " + filename
-		case "MODIFY":
-			filename = "M " + filename
-		case "DELETE":
-			filename = "D " + filename
-		case "MOVE":
-			filename = "R " + filename
+	return dir
+}
+
+func (node *fileTreeNode) compact() {
+	for _, dir := range node.dirs {
+		for len(dir.files) == 0 && len(dir.dirs) == 1 {
+			child := dir.dirs[0]
+			dir.name += "/" + child.name
+			dir.dirs = child.dirs
+			dir.files = child.files
 		}
 
-		err := hierr.Push(
-			*entries[file.Parent],
-			filename,
-		)
+		dir.compact()
+	}
+}
+
+func (node *fileTreeNode) sort() {
+	sort.Sort(fileTreeDirs(node.dirs))
+	sort.Sort(fileTreeFiles(node.files))
+
+	for _, dir := range node.dirs {
+		dir.sort()
+	}
+}
 
-		*entries[file.Parent] = err
+func (node *fileTreeNode) toError() error {
+	result := errors.New(node.name)
 
+	for _, dir := range node.dirs {
+		result = hierr.Push(result, dir.toError())
 	}
 
-	return entryRoot.Error()
+	for _, file := range node.files {
+		result = hierr.Push(result, file.String())
+	}
+
+	return result
+}
+
+type fileTreeDirs []*fileTreeNode
+
+func (dirs fileTreeDirs) Len() int {
+	return len(dirs)
+}
+
+func (dirs fileTreeDirs) Less(i, j int) bool {
+	return dirs[i].name < dirs[j].name
+}
+
+func (dirs fileTreeDirs) Swap(i, j int) {
+	dirs[i], dirs[j] = dirs[j], dirs[i]
+}
+
+type fileTreeFiles []ReviewFile
+
+func (files fileTreeFiles) Len() int {
+	return len(files)
+}
+
+func (files fileTreeFiles) Less(i, j int) bool {
+	return files[i].Name < files[j].Name
+}
+
+func (files fileTreeFiles) Swap(i, j int) {
+	files[i], files[j] = files[j], files[i]
 }
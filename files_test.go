@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/godiff"
+)
+
+func TestReviewFileString(t *testing.T) {
+	tests := []struct {
+		file     ReviewFile
+		expected string
+	}{
+		{
+			ReviewFile{Name: "main.go", ChangeType: "MODIFY"},
+			"M main.go",
+		},
+		{
+			ReviewFile{
+				Name: "main.go", ChangeType: "MODIFY",
+				Added: 10, Removed: 2, Comments: 3,
+			},
+			"M main.go (+10 -2, 3 comment(s))",
+		},
+		{
+			ReviewFile{
+				Name: "run.sh", ChangeType: "ADD", Added: 5, DstExec: true,
+			},
+			"A run.sh (+5 -0, +x)",
+		},
+		{
+			ReviewFile{
+				Name: "run.sh", ChangeType: "MODIFY", SrcExec: true,
+			},
+			"M run.sh (-x)",
+		},
+		{
+			ReviewFile{
+				Name: "new.go", ChangeType: "MOVE",
+				SrcPath: "lib/old.go", DstPath: "lib/new.go",
+				Unchanged: 90, Added: 1, Removed: 1,
+			},
+			"R lib/old.go -> lib/new.go (+1 -1, 90% unchanged)",
+		},
+	}
+
+	for _, test := range tests {
+		actual := test.file.String()
+		if actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestBuildFileTree(t *testing.T) {
+	files := ReviewFiles{
+		{Name: "main.go"},
+		{Name: "b.go", Parent: "src/pkg/util"},
+		{Name: "a.go", Parent: "src/pkg/util"},
+		{Name: "README.md"},
+		{Name: "x.go", Parent: "cmd"},
+		{Name: "y.go", Parent: "cmd/tool"},
+	}
+
+	expected := []string{
+		"./cmd/tool/y.go",
+		"./cmd/x.go",
+		"./src/pkg/util/a.go",
+		"./src/pkg/util/b.go",
+		"./README.md",
+		"./main.go",
+	}
+
+	actual := flattenFileTree(buildFileTree(files), "")
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected tree\n%#v\n%#v", expected, actual)
+	}
+
+	root := buildFileTree(files)
+	if root.dirs[1].name != "src/pkg/util" {
+		t.Fatalf("directories are not joined: %q", root.dirs[1].name)
+	}
+}
+
+func TestReviewFilesSetStats(t *testing.T) {
+	changeset := godiff.Changeset{}
+	err := json.Unmarshal([]byte(`{
+		"diffs": [
+			{
+				"source": {"toString": "main.go"},
+				"destination": {"toString": "main.go"},
+				"hunks": [{"segments": [
+					{"type": "REMOVED", "lines": [{"line": "a"}]},
+					{"type": "ADDED", "lines": [{"line": "b"}, {"line": "c"}]}
+				]}],
+				"lineComments": [{"id": 1, "comments": [{"id": 2}]}],
+				"fileComments": [{"id": 3}]
+			},
+			{
+				"source": {"toString": "removed.go"},
+				"hunks": [{"segments": [
+					{"type": "REMOVED", "lines": [{"line": "d"}]}
+				]}]
+			}
+		]
+	}`), &changeset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := ReviewFiles{
+		{Name: "main.go", DstPath: "main.go"},
+		{Name: "removed.go", DstPath: "removed.go"},
+	}
+
+	files.SetStats(&changeset)
+
+	expected := ReviewFiles{
+		{Name: "main.go", DstPath: "main.go", Added: 2, Removed: 1, Comments: 3},
+		{Name: "removed.go", DstPath: "removed.go", Removed: 1},
+	}
+
+	if !reflect.DeepEqual(expected, files) {
+		t.Fatalf("unexpected stats\n%#v\n%#v", expected, files)
+	}
+}
+
+func flattenFileTree(node *fileTreeNode, prefix string) []string {
+	result := []string{}
+	prefix += node.name + "/"
+
+	for _, dir := range node.dirs {
+		result = append(result, flattenFileTree(dir, prefix)...)
+	}
+
+	for _, file := range node.files {
+		result = append(result, prefix+file.Name)
+	}
+
+	return result
+}
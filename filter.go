@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PullRequestFilter selects and orders pull requests before listing them.
+// Empty fields do not filter anything.
+type PullRequestFilter struct {
+	Author          string
+	TargetBranch    string
+	NeedsApprovalOf string
+	Stale           time.Duration
+	Sort            string
+	Limit           int
+}
+
+var pullRequestSorts = map[string]func(a, b PullRequest) bool{
+	"updated": func(a, b PullRequest) bool {
+		return a.UpdatedDate > b.UpdatedDate
+	},
+	"created": func(a, b PullRequest) bool {
+		return a.CreatedDate > b.CreatedDate
+	},
+	"comments": func(a, b PullRequest) bool {
+		return a.Properties.CommentCount > b.Properties.CommentCount
+	},
+	"approvals": func(a, b PullRequest) bool {
+		return a.GetApprovalsCount() > b.GetApprovalsCount()
+	},
+}
+
+func (filter PullRequestFilter) Validate() error {
+	if _, ok := pullRequestSorts[filter.Sort]; filter.Sort != "" && !ok {
+		return fmt.Errorf(
+			"--sort should be one of: updated, created, comments, approvals",
+		)
+	}
+
+	return nil
+}
+
+func (filter PullRequestFilter) Apply(prs []PullRequest) []PullRequest {
+	return filter.Arrange(filter.Select(prs))
+}
+
+// Select returns matched pull requests without sorting and limiting them.
+func (filter PullRequestFilter) Select(prs []PullRequest) []PullRequest {
+	result := []PullRequest{}
+	for _, pr := range prs {
+		if filter.Match(pr) {
+			result = append(result, pr)
+		}
+	}
+
+	return result
+}
+
+// Arrange returns sorted pull requests, no more than limit (if non-zero).
+func (filter PullRequestFilter) Arrange(prs []PullRequest) []PullRequest {
+	result := append([]PullRequest{}, prs...)
+
+	if less, ok := pullRequestSorts[filter.Sort]; ok {
+		sort.Stable(pullRequestsSort{result, less})
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result
+}
+
+func (filter PullRequestFilter) Match(pr PullRequest) bool {
+	if filter.Author != "" &&
+		!strings.EqualFold(pr.Author.User.Name, filter.Author) {
+		return false
+	}
+
+	if filter.TargetBranch != "" &&
+		pr.ToRef.Id != filter.TargetBranch &&
+		pr.ToRef.Id != "refs/heads/"+filter.TargetBranch {
+		return false
+	}
+
+	if filter.NeedsApprovalOf != "" &&
+		!pr.NeedsApprovalOf(filter.NeedsApprovalOf) {
+		return false
+	}
+
+	if filter.Stale > 0 && time.Since(pr.UpdatedDate.AsTime()) < filter.Stale {
+		return false
+	}
+
+	return true
+}
+
+type pullRequestsSort struct {
+	prs  []PullRequest
+	less func(a, b PullRequest) bool
+}
+
+func (s pullRequestsSort) Len() int {
+	return len(s.prs)
+}
+
+func (s pullRequestsSort) Less(i, j int) bool {
+	return s.less(s.prs[i], s.prs[j])
+}
+
+func (s pullRequestsSort) Swap(i, j int) {
+	s.prs[i], s.prs[j] = s.prs[j], s.prs[i]
+}
+
+func (pr PullRequest) GetApprovalsCount() int {
+	count := 0
+	for _, reviewer := range pr.Reviewers {
+		if reviewer.Approved {
+			count++
+		}
+	}
+
+	return count
+}
+
+// NeedsApprovalOf returns true if user is a reviewer, who has not approved
+// pull request yet.
+func (pr PullRequest) NeedsApprovalOf(user string) bool {
+	for _, reviewer := range pr.Reviewers {
+		if strings.EqualFold(reviewer.User.Name, user) {
+			return !reviewer.Approved
+		}
+	}
+
+	return false
+}
+
+// ActivityFilter selects overview activities. Empty fields do not filter
+// anything.
+type ActivityFilter struct {
+	// Types is a set of activity types, see activityTypes.
+	Types map[string]bool
+	By    string
+	Since time.Time
+
+	// SinceId skips activities with lower or equal id.
+	SinceId int64
+}
+
+var activityTypes = map[string][]string{
+	"comments":  {"COMMENTED"},
+	"rescopes":  {"RESCOPED"},
+	"approvals": {"APPROVED", "UNAPPROVED", "REVIEWED"},
+	"updates":   {"UPDATED"},
+	"status":    {"OPENED", "MERGED", "DECLINED", "REOPENED", "DELETED"},
+}
+
+// ParseActivityTypes parses comma-separated list of activity types.
+func ParseActivityTypes(value string) (map[string]bool, error) {
+	types := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := activityTypes[name]; !ok {
+			return nil, fmt.Errorf(
+				"unknown activity type '%s', should be one of: "+
+					"comments, rescopes, approvals, updates, status",
+				name,
+			)
+		}
+
+		types[name] = true
+	}
+
+	return types, nil
+}
+
+// ParseDate parses either date like '2016-01-02' (optionally with time
+// '15:04') or age relative to now like '7d'.
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		date, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return date, nil
+		}
+	}
+
+	age, err := ParseAge(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			"date should be either like 2016-01-02 or 7d: %s", value,
+		)
+	}
+
+	return time.Now().Add(-age), nil
+}
+
+func (filter ActivityFilter) IsEmpty() bool {
+	return len(filter.Types) == 0 && filter.By == "" &&
+		filter.Since.IsZero() && filter.SinceId == 0
+}
+
+func (filter ActivityFilter) Match(head activityHead) bool {
+	if head.Id <= filter.SinceId {
+		return false
+	}
+
+	if len(filter.Types) > 0 {
+		matched := false
+		for name := range filter.Types {
+			for _, action := range activityTypes[name] {
+				matched = matched || action == head.Action
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if filter.By != "" && !strings.EqualFold(head.User.Name, filter.By) &&
+		!strings.EqualFold(head.User.DisplayName, filter.By) {
+		return false
+	}
+
+	if !filter.Since.IsZero() && head.CreatedDate.AsTime().Before(filter.Since) {
+		return false
+	}
+
+	return true
+}
+
+// ParseAge parses duration, additionally allowing days and weeks, like
+// '7d' or '2w'.
+func ParseAge(value string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	for suffix, unit := range units {
+		if strings.HasSuffix(value, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil {
+				return 0, err
+			}
+
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPullRequestFilter(t *testing.T) {
+	now := UnixTimestamp(time.Now().Unix() * 1000)
+	weekAgo := UnixTimestamp(time.Now().Add(-8*24*time.Hour).Unix() * 1000)
+
+	prs := []PullRequest{}
+	err := json.Unmarshal([]byte(`[
+		{
+			"id": 1, "toRef": {"id": "refs/heads/master"},
+			"author": {"user": {"name": "alice"}},
+			"reviewers": [{"approved": true, "user": {"name": "me"}}],
+			"properties": {"commentCount": 1}
+		},
+		{
+			"id": 2, "toRef": {"id": "refs/heads/dev"},
+			"author": {"user": {"name": "bob"}},
+			"reviewers": [{"approved": false, "user": {"name": "me"}}],
+			"properties": {"commentCount": 5}
+		},
+		{
+			"id": 3, "toRef": {"id": "refs/heads/master"},
+			"author": {"user": {"name": "alice"}},
+			"properties": {"commentCount": 3}
+		}
+	]`), &prs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prs[0].UpdatedDate = now
+	prs[1].UpdatedDate = weekAgo
+	prs[2].UpdatedDate = now
+
+	tests := []struct {
+		filter   PullRequestFilter
+		expected []int64
+	}{
+		{PullRequestFilter{}, []int64{1, 2, 3}},
+		{PullRequestFilter{Author: "Alice"}, []int64{1, 3}},
+		{PullRequestFilter{TargetBranch: "dev"}, []int64{2}},
+		{PullRequestFilter{NeedsApprovalOf: "me"}, []int64{2}},
+		{PullRequestFilter{Stale: 7 * 24 * time.Hour}, []int64{2}},
+		{PullRequestFilter{Sort: "comments"}, []int64{2, 3, 1}},
+		{PullRequestFilter{Sort: "approvals", Limit: 2}, []int64{1, 2}},
+	}
+
+	for _, test := range tests {
+		actual := []int64{}
+		for _, pr := range test.filter.Apply(prs) {
+			actual = append(actual, pr.Id)
+		}
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected result of filter %#v\n%v\n%v",
+				test.filter, test.expected, actual)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+	}
+
+	for value, expected := range tests {
+		actual, err := ParseAge(value)
+		if err != nil || actual != expected {
+			t.Fatalf("unexpected age of '%s': %s (%v)", value, actual, err)
+		}
+	}
+}
+
+func TestActivityFilter(t *testing.T) {
+	types, err := ParseActivityTypes("comments,approvals")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since, err := ParseDate("2016-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := ActivityFilter{Types: types, By: "alice", Since: since}
+
+	date := UnixTimestamp(since.Add(time.Hour).Unix() * 1000)
+	user := activityUser{Name: "alice", DisplayName: "Alice"}
+
+	tests := []struct {
+		head    activityHead
+		matched bool
+	}{
+		{activityHead{1, "COMMENTED", date, user}, true},
+		{activityHead{2, "UNAPPROVED", date, user}, true},
+		{activityHead{3, "RESCOPED", date, user}, false},
+		{activityHead{4, "COMMENTED", date, activityUser{Name: "bob"}}, false},
+		{activityHead{5, "COMMENTED", date - 2*3600*1000, user}, false},
+	}
+
+	for _, test := range tests {
+		if filter.Match(test.head) != test.matched {
+			t.Fatalf("unexpected match result for %#v", test.head)
+		}
+	}
+
+	_, err = ParseActivityTypes("comments,likes")
+	if err == nil {
+		t.Fatal("error expected for unknown activity type")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+// RemovalGuard protects from accidental deletion of comments, e.g. when
+// whole review file was cleared in the editor. Removals of comments of
+// somebody else are rejected earlier by ValidateChanges.
+type RemovalGuard struct {
+	MaxRemovals int
+	Force       bool
+}
+
+// Check returns all comment removals found in changes if there are more of
+// them than allowed.
+func (guard RemovalGuard) Check(changes []ReviewChange) []CommentRemoved {
+	if guard.Force {
+		return nil
+	}
+
+	removals := []CommentRemoved{}
+
+	for _, change := range changes {
+		removal, ok := change.(CommentRemoved)
+		if !ok {
+			continue
+		}
+
+		removals = append(removals, removal)
+	}
+
+	if len(removals) > guard.MaxRemovals {
+		return removals
+	}
+
+	return nil
+}
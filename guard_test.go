@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/seletskiy/godiff"
+)
+
+func TestRemovalGuardCheck(t *testing.T) {
+	first := &godiff.Comment{Id: 1}
+	second := &godiff.Comment{Id: 2}
+
+	tests := []struct {
+		guard    RemovalGuard
+		changes  []ReviewChange
+		expected int
+	}{
+		{
+			RemovalGuard{MaxRemovals: 1},
+			[]ReviewChange{CommentRemoved{first}},
+			0,
+		},
+		{
+			RemovalGuard{MaxRemovals: 0},
+			[]ReviewChange{CommentRemoved{first}, ReviewCommentAdded{second}},
+			1,
+		},
+		{
+			RemovalGuard{MaxRemovals: 1},
+			[]ReviewChange{CommentRemoved{first}, CommentRemoved{second}},
+			2,
+		},
+		{
+			RemovalGuard{MaxRemovals: 0, Force: true},
+			[]ReviewChange{CommentRemoved{first}, CommentRemoved{second}},
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		actual := test.guard.Check(test.changes)
+		if len(actual) != test.expected {
+			t.Fatalf("unexpected number of unsafe removals: %d instead of %d",
+				len(actual), test.expected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	descriptionBegin = "### --- description (edit to change) ---"
+	descriptionEnd   = "### --- end of description ---"
+)
+
+var reOriginalDescriptionMarker = regexp.MustCompile(
+	`ash: original-description=(".*")`,
+)
+
+// ReviewHeader is pull request metadata written at the top of the overview.
+// Only description can be changed by editing review file.
+type ReviewHeader struct {
+	Title       string
+	Author      string
+	FromBranch  string
+	ToBranch    string
+	Reviewers   []string
+	Status      string
+	Description string
+}
+
+type DescriptionModified struct {
+	description string
+	original    string
+}
+
+func (modified DescriptionModified) String() string {
+	return fmt.Sprintf(
+		"Description modified:\n%s",
+		indent(modified.description, " > "),
+	)
+}
+
+func (modified DescriptionModified) GetPayload() map[string]interface{} {
+	return map[string]interface{}{
+		"description": modified.description,
+	}
+}
+
+func NewReviewHeader(info *PullRequestInfo) *ReviewHeader {
+	header := &ReviewHeader{
+		Title:       info.Title,
+		Author:      info.Author.User.DisplayName,
+		FromBranch:  info.FromRef.DisplayId,
+		ToBranch:    info.ToRef.DisplayId,
+		Status:      info.State,
+		Description: info.Description,
+	}
+
+	for _, reviewer := range info.Reviewers {
+		name := reviewer.User.DisplayName
+		if reviewer.Approved {
+			name += " (approved)"
+		}
+
+		header.Reviewers = append(header.Reviewers, name)
+	}
+
+	return header
+}
+
+func (header *ReviewHeader) Write(writer io.Writer) error {
+	lines := []string{
+		"Title: " + header.Title,
+		"Author: " + header.Author,
+		"Branches: " + header.FromBranch + " -> " + header.ToBranch,
+		"Reviewers: " + strings.Join(header.Reviewers, ", "),
+		"Status: " + header.Status,
+		"",
+	}
+
+	for _, line := range lines {
+		_, err := fmt.Fprintln(writer, strings.TrimSpace("### "+line))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(
+		writer, "%s\n%s\n%s\n\n",
+		descriptionBegin, header.Description, descriptionEnd,
+	)
+
+	return err
+}
+
+// readReviewHeader cuts description block out of review file, returning
+// rest of the file and header with description, if block is found.
+func readReviewHeader(r io.Reader) (string, *ReviewHeader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var header *ReviewHeader
+
+	rest := []string{}
+	description := []string{}
+	inDescription := false
+
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case line == descriptionBegin && header == nil:
+			header = &ReviewHeader{}
+			inDescription = true
+		case line == descriptionEnd && inDescription:
+			inDescription = false
+		case inDescription:
+			description = append(description, line)
+		default:
+			rest = append(rest, line)
+		}
+	}
+
+	if inDescription {
+		return "", nil, fmt.Errorf("end of description is not found")
+	}
+
+	if header != nil {
+		header.Description = strings.Join(description, "\n")
+	}
+
+	return strings.Join(rest, "\n"), header, nil
+}
+
+// getOriginalDescription returns description, which review file was written
+// with, if review has header.
+func (review *Review) getOriginalDescription() *string {
+	if review.description != nil {
+		return review.description
+	}
+
+	if review.header != nil {
+		return &review.header.Description
+	}
+
+	return nil
+}
+
+// formatOriginalDescription returns marker of description, which review file
+// was written with; description is quoted to fit into single line.
+func formatOriginalDescription(description string) string {
+	return "ash: original-description=" + strconv.Quote(description)
+}
+
+func readOriginalDescription(text string) *string {
+	matches := reOriginalDescriptionMarker.FindStringSubmatch(text)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	description, err := strconv.Unquote(matches[1])
+	if err != nil {
+		logger.Warning("can not read original description: %s", err.Error())
+		return nil
+	}
+
+	return &description
+}
+
+// compareDescriptions returns change of description made in another review,
+// comparing it with description, which review file was written with, or,
+// for files of older versions, with description of current review.
+func compareDescriptions(current *Review, another *Review) []ReviewChange {
+	if another.header == nil {
+		return nil
+	}
+
+	original := another.description
+	if original == nil {
+		original = current.getOriginalDescription()
+	}
+
+	if original == nil {
+		return nil
+	}
+
+	edited := strings.TrimSpace(another.header.Description)
+	if strings.TrimSpace(*original) == edited {
+		return nil
+	}
+
+	return []ReviewChange{
+		DescriptionModified{edited, strings.TrimSpace(*original)},
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReviewHeaderDescription(t *testing.T) {
+	header := &ReviewHeader{
+		Title:       "Fix everything",
+		Author:      "Alice",
+		FromBranch:  "fix",
+		ToBranch:    "master",
+		Reviewers:   []string{"Bob (approved)"},
+		Status:      "OPEN",
+		Description: "It was broken.\n\nNow it is not.",
+	}
+
+	buffer := &bytes.Buffer{}
+	err := header.Write(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer.WriteString("--- a\n+++ a\n")
+
+	rest, read, err := readReviewHeader(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read == nil || read.Description != header.Description {
+		t.Fatalf("description is not read back: %#v", read)
+	}
+
+	expected := "### Title: Fix everything\n" +
+		"### Author: Alice\n" +
+		"### Branches: fix -> master\n" +
+		"### Reviewers: Bob (approved)\n" +
+		"### Status: OPEN\n" +
+		"###\n" +
+		"\n" +
+		"--- a\n+++ a\n"
+
+	if rest != expected {
+		t.Fatalf("unexpected rest of review file:\n%q\n%q", expected, rest)
+	}
+}
+
+func TestCompareDescriptions(t *testing.T) {
+	current := &Review{header: &ReviewHeader{Description: "old\n"}}
+
+	tests := []struct {
+		another  *Review
+		expected []ReviewChange
+	}{
+		{&Review{}, nil},
+		{&Review{header: &ReviewHeader{Description: "old"}}, nil},
+		{
+			&Review{header: &ReviewHeader{Description: "new\n\n"}},
+			[]ReviewChange{DescriptionModified{"new", "old"}},
+		},
+	}
+
+	for _, test := range tests {
+		actual := compareDescriptions(current, test.another)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected changes\n%#v\n%#v", test.expected, actual)
+		}
+	}
+}
+
+func TestCompareDescriptionsWithOriginal(t *testing.T) {
+	current := &Review{header: &ReviewHeader{Description: "changed remotely"}}
+
+	stale := "old \"one\"\n\nwith paragraphs"
+	original := readOriginalDescription(
+		"### " + formatOriginalDescription(stale) + "\n",
+	)
+	if original == nil || *original != stale {
+		t.Fatalf("original description is not read back: %#v", original)
+	}
+
+	unchanged := &Review{
+		header:      &ReviewHeader{Description: stale},
+		description: original,
+	}
+
+	if changes := compareDescriptions(current, unchanged); changes != nil {
+		t.Fatalf("unchanged description is modified: %#v", changes)
+	}
+
+	edited := &Review{
+		header:      &ReviewHeader{Description: "new"},
+		description: original,
+	}
+
+	expected := []ReviewChange{DescriptionModified{"new", stale}}
+
+	actual := compareDescriptions(current, edited)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected changes\n%#v\n%#v", expected, actual)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -30,6 +31,8 @@ var (
 
 var configPath = os.Getenv("HOME") + "/.config/ash/ashrc"
 
+var cachePath = os.Getenv("HOME") + "/.cache/ash"
+
 var logger = logging.MustGetLogger("main")
 
 var tmpWorkDir = ""
@@ -42,8 +45,7 @@ const startUrlExample = "http[s]://<host>/(users|projects)/<project>/repos/<repo
 
 type CmdLineArgs string
 
-func parseCmdLine(cmd []string) (map[string]interface{}, error) {
-	help := `Atlassian Stash Reviewer.
+const usage = `Atlassian Stash Reviewer.
 
 Most convenient usage is specify pull request url and file you want to review:
   ash ` + startUrlExample + ` review <file-to-review>
@@ -67,17 +69,42 @@ apply all changes made to the review.
 
 If <file-name> is omitted, ash welcomes you to review the overview.
 
+Reviews can be done offline: 'fetch' command downloads overview and all files
+(or only specified one) into the cache, and '--offline' flag makes review use
+cached copy and queue changes to the outbox instead of sending them. 'sync'
+command sends all queued changes later.
+
+Review file saved by --output can be applied later by 'apply' command. Pull
+request and file to review are taken from the ash modeline at the end of the
+file.
+
+Replacement for the commented line can be suggested by wrapping it into
+'~~~suggestion' and '~~~' lines inside the comment. Suggestions left in pull
+request can be applied to the local working tree by 'apply-suggestions'
+command (use -i to choose which ones to apply).
+
 'ls' command can be used to list various things, including:
 * files in pull request;
 * opened/merged/declined pull requests for repo;
-* repositories in specified project [NOT IMPLEMENTED];
-* projects [NOT IMPLEMENTED];
+* repositories in specified project ('ls-repos');
+* projects ('ls-projects');
 
 Usage:
-  ash [options] inbox [-d] [(reviewer|author|all)]
+  ash [options] inbox [-d] [--news] [(reviewer|author|all)]
+  ash [options] ls-projects [<filter>] [--json]
+  ash [options] <project> ls-repos [<filter>] [--json]
+  ash [options] search <project> [<text>] [-d]
   ash [options] <project>/<repo> ls-reviews [-d] [(open|merged|declined)]
+  ash [options] <project>/<repo> create <branch> [<target-branch>]
   ash [options] <project>/<repo>/<pr> ls
   ash [options] <project>/<repo>/<pr> (approve|decline|merge)
+  ash [options] <project>/<repo>/<pr> apply-suggestions
+  ash [options] <project>/<repo>/<pr> news
+  ash [options] <project>/<repo>/<pr> edit
+  ash [options] apply <review-file>
+  ash [options] --refresh=<file>
+  ash [options] <project>/<repo>/<pr> fetch [<file-name>] [-w]
+  ash [options] sync
   ash [options] <project>/<repo>/<pr> [review] [<file-name>] [-w]
   ash -h | --help
   ash -v | --version
@@ -90,6 +117,7 @@ Options:
   -d                 Show descriptions for the listed PRs.
   -l=<count>         Number of activities to retrieve. [default: 1000]
   -w                 Ignore whitespaces
+  --context=<lines>  Number of context lines around changes in file review.
   -e=<editor>        Editor to use. This has priority over $EDITOR env var.
   -i                 Interactive mode. Ask before commiting changes.
   --debug=<level>    Verbosity [default: 0].
@@ -102,20 +130,72 @@ Options:
   --project=<proj>   Use to specify default project that can be used when
                       serching pull requests. Can be set in either <project> or
                       <project>/<repo> format.
+  --wrap=<cols>      Wrap long lines of comments to specified width. Comment
+                      paragraphs are joined back into single lines before
+                      sending.
+  --max-removals=<n> Ask for confirmation if more than specified number of
+                      comments are going to be deleted. [default: 3]
+  --force            Do not ask for confirmation before deleting comments.
+                      Comments of somebody else are never deleted.
+  --refresh=<file>   Merge new remote comments into partially edited review
+                      file, keeping local changes, and continue editing it.
+                      If pull request is not specified, it is located using
+                      ash modeline of the file.
+  --offline          Review cached copy of pull request and queue changes to
+                      be sent by 'sync' command.
+  --cache-ttl=<ttl>  Use cached Stash responses younger than specified
+                      duration without revalidation. By default every cached
+                      response is revalidated. [default: 0s]
+  --no-cache         Do not cache Stash responses in ~/.cache/ash.
+  --reviewers=<users>
+                     Comma-separated reviewers to add to created or edited
+                      pull request in addition to default ones.
+  --limit=<n>        Maximum number of listed items.
+  --sort=<field>     Sort listed pull requests by one of: updated, created,
+                      comments, approvals.
+  --author=<user>    List only pull requests of specified author.
+  --target-branch=<branch>
+                     List only pull requests to specified branch.
+  --needs-my-approval
+                     List only pull requests not approved by you yet.
+  --state=<state>    Search pull requests in specified state: open, merged,
+                      declined or all. [default: open]
+  --participant=<user>
+                     Search pull requests with specified participant.
+  --stale=<age>      List only pull requests not updated for specified time,
+                      e.g. 7d, 2w or 12h.
+  --json             Output list in JSON format.
+  --profile=<name>   Use arguments from named profile of config. By default
+                      profile is selected by host of pull request URL.
+  --activity=<types> Show only specified comma-separated types of activities
+                      in overview: comments, rescopes, approvals, updates,
+                      status.
+  --by=<user>        Show only activities of specified user in overview.
+  --since=<date>     Show only activities since specified date in overview,
+                      either like 2016-01-02 or 7d.
+  --commit-diffs     Show read-only diff of every commit added to pull
+                      request in overview.
+  --news             Show number of activities in inbox pull requests, which
+                      are not seen by 'news' command yet.
+  --watch=<interval> Poll inbox with specified interval (e.g. 30s or 5m) and
+                      print new pull requests, comments and approvals.
+  --on-change=<cmd>  Command to run for every inbox change in --watch mode;
+                      change is passed to its stdin as JSON.
   --no-color         Do not use color in output.
   --reset-colors     Start with terminal style-reset sequence. Most useful with
                       vim.
 `
 
-	args, err := docopt.Parse(help, cmd, true, "1.3", false, false)
+func parseCmdLine(cmd []string) (map[string]interface{}, error) {
+	args, err := docopt.Parse(usage, cmd, true, "1.3", false, false)
 
 	if _, ok := err.(*docopt.UserError); ok {
 		fmt.Println()
 		fmt.Println("Command line entered is invalid.")
 		fmt.Println()
 		fmt.Println(
-			"Arguments were merged with config values and " +
-				"the resulting command line is:")
+			"Arguments were merged with config values, aliases were " +
+				"expanded and the resulting command line is:")
 		fmt.Printf("\t%s\n\n", CmdLineArgs(fmt.Sprintf("%s", cmd)).Redacted())
 		os.Exit(1)
 	}
@@ -128,7 +208,15 @@ Options:
 }
 
 func main() {
-	rawArgs := mergeArgsWithConfig(configPath)
+	config := loadConfig(configPath)
+
+	cmdLine, err := ExpandAlias(os.Args[1:], config.Aliases, usage)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	rawArgs := mergeArgsWithConfig(config, cmdLine)
 
 	args, err := parseCmdLine(rawArgs)
 	if err != nil {
@@ -154,6 +242,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	if args["--refresh"] != nil && args["<project>/<repo>/<pr>"] == nil {
+		useModelineArgs(args, args["--refresh"].(string))
+	}
+
+	if args["apply"].(bool) {
+		useModelineArgs(args, args["<review-file>"].(string))
+		args["--input"] = args["<review-file>"]
+	}
+
+	user := args["--user"].(string)
+	pass := args["--pass"].(string)
+
+	auth := gopencils.BasicAuth{user, pass}
+
+	if args["sync"].(bool) {
+		syncMode(args, auth)
+		return
+	}
+
 	uri := parseUri(args)
 
 	if !strings.HasPrefix(uri.base, "http") {
@@ -162,19 +269,24 @@ func main() {
 
 	uri.base = strings.TrimSuffix(uri.base, "/")
 
-	user := args["--user"].(string)
-	pass := args["--pass"].(string)
-
-	auth := gopencils.BasicAuth{user, pass}
-	api := Api{uri.base, auth, nil}
+	api := Api{uri.base, auth, nil, getResponseCache(args)}
 	project := Project{&api, uri.project}
+
+	applyScopedConfig(args, cmdLine, config, project.Key(), uri.repo)
+
 	repo := project.GetRepo(uri.repo)
 
 	switch {
 	case args["<project>/<repo>/<pr>"] != nil:
-		reviewMode(args, repo, uri.pr)
+		reviewMode(args, repo, uri.pr, config)
 	case args["<project>/<repo>"] != nil:
-		repoMode(args, repo)
+		repoMode(args, repo, config)
+	case args["search"].(bool):
+		searchMode(args, project)
+	case args["<project>"] != nil:
+		projectMode(args, project)
+	case args["ls-projects"].(bool):
+		showProjects(args, api)
 	case args["inbox"].(bool):
 		inboxMode(args, api)
 	}
@@ -186,6 +298,63 @@ func main() {
 	}
 }
 
+func getResponseCache(args map[string]interface{}) *ResponseCache {
+	if args["--no-cache"].(bool) {
+		return nil
+	}
+
+	ttl, err := time.ParseDuration(args["--cache-ttl"].(string))
+	if err != nil {
+		fmt.Println("--cache-ttl should be a duration, like 30s or 5m.")
+		os.Exit(1)
+	}
+
+	return &ResponseCache{
+		Dir: cachePath + "/http",
+		TTL: ttl,
+	}
+}
+
+func readAshModeline(reviewFile string) *AshModeline {
+	file, err := os.Open(reviewFile)
+	if err != nil {
+		return nil
+	}
+
+	defer file.Close()
+
+	modeline, err := ParseAshModeline(file)
+	if err != nil {
+		return nil
+	}
+
+	return modeline
+}
+
+func useModelineArgs(args map[string]interface{}, reviewFile string) {
+	file, err := os.Open(reviewFile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	defer file.Close()
+
+	modeline, err := ParseAshModeline(file)
+	if err != nil {
+		fmt.Printf("Can not locate pull request from %s: %s\n",
+			reviewFile, err.Error())
+		os.Exit(1)
+	}
+
+	logger.Debug("using pull request from modeline: %s", modeline.URL)
+
+	args["<project>/<repo>/<pr>"] = modeline.URL
+	args["<file-name>"] = nil
+	if !modeline.Overview {
+		args["<file-name>"] = modeline.File
+	}
+}
+
 func setupLogger(args map[string]interface{}) {
 	debugLogFile, err := os.Create(tmpWorkDir + "/debug.log")
 	if err != nil {
@@ -237,20 +406,257 @@ func inboxMode(args map[string]interface{}, api Api) {
 		}
 	}
 
+	filter := getPullRequestFilter(args)
+
+	if args["--watch"] != nil {
+		interval, err := ParseAge(args["--watch"].(string))
+		if err != nil || interval <= 0 {
+			fmt.Println("--watch should be an interval like 30s or 5m.")
+			os.Exit(1)
+		}
+
+		hook := ""
+		if args["--on-change"] != nil {
+			hook = args["--on-change"].(string)
+		}
+
+		watchInbox(api, roles, filter, interval, hook)
+		return
+	}
+
 	channels := make(map[string]chan []PullRequest)
 	for _, role := range roles {
 		channels[role] = requestInboxFor(role, api)
 	}
 
-	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	pullRequests := []PullRequest{}
 	for _, role := range roles {
-		for _, pullRequest := range <-channels[role] {
-			printPullRequest(writer, pullRequest, args["-d"].(bool), false)
+		pullRequests = append(pullRequests, <-channels[role]...)
+	}
+
+	seen := SeenActivities{}
+	if args["--news"].(bool) {
+		seen = LoadSeenActivities()
+	}
+
+	pullRequests = filter.Apply(pullRequests)
+
+	news := make([]chan string, len(pullRequests))
+	if args["--news"].(bool) {
+		requests := make(chan struct{}, newsRequests)
+		for i, pullRequest := range pullRequests {
+			news[i] = requestNewsCount(&api, pullRequest, seen, requests)
 		}
 	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	for i, pullRequest := range pullRequests {
+		if args["--news"].(bool) {
+			fmt.Fprintf(writer, "%s\t", <-news[i])
+		}
+
+		printPullRequest(writer, pullRequest, args["-d"].(bool), false)
+	}
 	writer.Flush()
 }
 
+// requestNewsCount counts activities of pull request not seen yet; requests
+// channel limits number of simultaneous requests to Stash.
+func requestNewsCount(
+	api *Api, pr PullRequest, seen SeenActivities, requests chan struct{},
+) chan string {
+	result := make(chan string, 1)
+
+	go func() {
+		requests <- struct{}{}
+		defer func() { <-requests }()
+
+		// seen is only read here, so it can be shared between goroutines
+		bound := getInboxPullRequest(api, pr)
+
+		_, activity, err := bound.GetFilteredActivities(
+			fmt.Sprint(newsLimit), ActivityFilter{SinceId: seen.Get(&bound)},
+		)
+		switch {
+		case err != nil:
+			logger.Warning("can not get activities of %s: %s",
+				getPullRequestSlug(pr), err.Error())
+			result <- "?"
+		case activity.Count == 0:
+			result <- ""
+		case activity.Count >= newsLimit:
+			result <- fmt.Sprintf("+%d+", activity.Count)
+		default:
+			result <- fmt.Sprintf("+%d", activity.Count)
+		}
+	}()
+
+	return result
+}
+
+func showNews(
+	pr PullRequest, activitiesLimit string, activityFilter ActivityFilter,
+) {
+	seen := LoadSeenActivities()
+
+	activityFilter.SinceId = seen.Get(&pr)
+
+	review, activity, err := pr.GetFilteredActivities(
+		activitiesLimit, activityFilter,
+	)
+	if err != nil {
+		logger.Criticalf("can not get activities: %s", err.Error())
+		os.Exit(1)
+	}
+
+	if activity.Count == 0 {
+		fmt.Println("No news since last run.")
+	} else {
+		err = WriteReview(review, os.Stdout)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	seen.Set(&pr, activity.LastId)
+
+	err = seen.Save()
+	if err != nil {
+		logger.Warning("can not save seen activities: %s", err.Error())
+	}
+}
+
+func getActivityFilter(args map[string]interface{}) ActivityFilter {
+	filter := ActivityFilter{}
+
+	if args["--activity"] != nil {
+		types, err := ParseActivityTypes(args["--activity"].(string))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		filter.Types = types
+	}
+
+	if args["--by"] != nil {
+		filter.By = args["--by"].(string)
+	}
+
+	if args["--since"] != nil {
+		since, err := ParseDate(args["--since"].(string))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		filter.Since = since
+	}
+
+	return filter
+}
+
+func getPullRequestFilter(args map[string]interface{}) PullRequestFilter {
+	filter := PullRequestFilter{}
+
+	if args["--author"] != nil {
+		filter.Author = args["--author"].(string)
+	}
+
+	if args["--target-branch"] != nil {
+		filter.TargetBranch = args["--target-branch"].(string)
+	}
+
+	if args["--needs-my-approval"].(bool) {
+		filter.NeedsApprovalOf = args["--user"].(string)
+	}
+
+	if args["--stale"] != nil {
+		stale, err := ParseAge(args["--stale"].(string))
+		if err != nil {
+			fmt.Println("--stale should be an age like 7d, 2w or 12h.")
+			os.Exit(1)
+		}
+
+		filter.Stale = stale
+	}
+
+	if args["--sort"] != nil {
+		filter.Sort = args["--sort"].(string)
+	}
+
+	_, filter.Limit = getListArgs(args)
+
+	err := filter.Validate()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	return filter
+}
+
+func watchInbox(
+	api Api, roles []string, filter PullRequestFilter,
+	interval time.Duration, hook string,
+) {
+	if api.Cache != nil {
+		// every poll should at least revalidate inbox
+		api.Cache.TTL = 0
+	}
+
+	var previous []PullRequest
+	for first := true; ; time.Sleep(interval) {
+		current := []PullRequest{}
+
+		var err error
+		for _, role := range roles {
+			var reviews []PullRequest
+			reviews, err = api.GetInbox(role)
+			if err != nil {
+				break
+			}
+
+			current = append(current, reviews...)
+		}
+
+		if err != nil {
+			logger.Warning("can not retrieve inbox: %s", err.Error())
+			continue
+		}
+
+		// limit and order of pull requests can change without anything
+		// happening to them, so whole sets are compared
+		current = filter.Select(current)
+
+		if first {
+			logger.Info(
+				"watching %d pull requests in inbox",
+				len(filter.Arrange(current)),
+			)
+			first = false
+		} else {
+			for _, event := range ArrangeInboxEvents(
+				DiffInbox(previous, current),
+				append(filter.Arrange(current), filter.Arrange(previous)...),
+			) {
+				fmt.Println(event)
+
+				if hook == "" {
+					continue
+				}
+
+				err := runHook(hook, event)
+				if err != nil {
+					logger.Warning("hook failed: %s", err.Error())
+				}
+			}
+		}
+
+		previous = current
+	}
+}
+
 func requestInboxFor(role string, api Api) chan []PullRequest {
 	resultChannel := make(chan []PullRequest, 0)
 
@@ -270,7 +676,9 @@ func requestInboxFor(role string, api Api) chan []PullRequest {
 	return resultChannel
 }
 
-func reviewMode(args map[string]interface{}, repo Repo, pr int64) {
+func reviewMode(
+	args map[string]interface{}, repo Repo, pr int64, config Config,
+) {
 	editor := os.Getenv("EDITOR")
 	if args["-e"] != nil {
 		editor = args["-e"].(string)
@@ -286,6 +694,8 @@ func reviewMode(args map[string]interface{}, repo Repo, pr int64) {
 		input = args["--input"].(string)
 	}
 
+	applyMode := args["apply"].(bool)
+
 	output := ""
 	if args["--output"] != nil {
 		output = args["--output"].(string)
@@ -296,8 +706,19 @@ func reviewMode(args map[string]interface{}, repo Repo, pr int64) {
 		ignoreWhitespaces = true
 	}
 
+	contextLines := ""
+	if args["--context"] != nil {
+		contextLines = args["--context"].(string)
+		if _, err := strconv.Atoi(contextLines); err != nil {
+			fmt.Println("--context should be a number of lines.")
+			os.Exit(1)
+		}
+	}
+
 	activitiesLimit := args["-l"].(string)
 
+	activityFilter := getActivityFilter(args)
+
 	pullRequest := repo.GetPullRequest(pr)
 
 	origin := ""
@@ -305,8 +726,37 @@ func reviewMode(args map[string]interface{}, repo Repo, pr int64) {
 		origin = args["--origin"].(string)
 	}
 
+	refresh := ""
+	if args["--refresh"] != nil {
+		refresh = args["--refresh"].(string)
+	}
+
+	offline := args["--offline"].(bool)
+
 	interactiveMode := args["-i"].(bool)
 
+	wrapWidth := 0
+	if args["--wrap"] != nil {
+		width, err := strconv.Atoi(args["--wrap"].(string))
+		if err != nil {
+			fmt.Println("--wrap should be a number of columns.")
+			os.Exit(1)
+		}
+
+		wrapWidth = width
+	}
+
+	maxRemovals, err := strconv.Atoi(args["--max-removals"].(string))
+	if err != nil {
+		fmt.Println("--max-removals should be a number.")
+		os.Exit(1)
+	}
+
+	removalGuard := RemovalGuard{
+		MaxRemovals: maxRemovals,
+		Force:       args["--force"].(bool),
+	}
+
 	switch {
 	case args["ls"]:
 		showFilesList(pullRequest)
@@ -316,12 +766,25 @@ func reviewMode(args map[string]interface{}, repo Repo, pr int64) {
 		decline(pullRequest)
 	case args["merge"].(bool):
 		merge(pullRequest)
+	case args["apply-suggestions"].(bool):
+		applySuggestions(pullRequest, interactiveMode)
+	case args["news"].(bool):
+		showNews(pullRequest, activitiesLimit, activityFilter)
+	case args["edit"].(bool):
+		editPullRequest(pullRequest, config, editor, getReviewersArg(args))
+	case args["fetch"].(bool):
+		fetch(
+			pullRequest, path,
+			activitiesLimit, ignoreWhitespaces, contextLines,
+		)
 	default:
 		review(
 			pullRequest, editor, path,
-			origin, input, output,
-			activitiesLimit, ignoreWhitespaces,
-			interactiveMode,
+			origin, input, applyMode, output, refresh,
+			activitiesLimit, activityFilter, args["--commit-diffs"].(bool),
+			ignoreWhitespaces, contextLines,
+			interactiveMode, wrapWidth,
+			removalGuard, offline,
 		)
 	}
 }
@@ -359,7 +822,88 @@ func merge(pr PullRequest) {
 	fmt.Println("Pull request successfully merged")
 }
 
-func repoMode(args map[string]interface{}, repo Repo) {
+func applySuggestions(pr PullRequest, interactiveMode bool) {
+	logger.Debug("collecting suggestions from pr")
+	suggestions, err := pr.GetSuggestions()
+	if err != nil {
+		logger.Criticalf("error retrieving suggestions: %s", err.Error())
+		os.Exit(1)
+	}
+
+	accepted := []Suggestion{}
+	for i, suggestion := range suggestions {
+		if !interactiveMode {
+			accepted = append(accepted, suggestion)
+			continue
+		}
+
+		fmt.Printf("%d. %s\n\n", i+1, suggestion.String())
+
+		if askYesNo("Apply this suggestion?", true) {
+			accepted = append(accepted, suggestion)
+		}
+	}
+
+	if len(accepted) == 0 {
+		fmt.Println("No suggestions to apply.")
+		os.Exit(2)
+	}
+
+	patchPath := tmpWorkDir + "/suggestions.patch"
+	err = ioutil.WriteFile(
+		patchPath, []byte(MakeSuggestionsPatch(accepted)), 0644,
+	)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	topLevel, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		logger.Criticalf("can not find git working tree: %s", err.Error())
+		os.Exit(1)
+	}
+
+	logger.Debug("applying patch %s", patchPath)
+	applyCmd := exec.Command("git", "apply", "--unidiff-zero", patchPath)
+	applyCmd.Dir = strings.TrimSpace(string(topLevel))
+	applyCmd.Stdout = os.Stdout
+	applyCmd.Stderr = os.Stderr
+
+	err = applyCmd.Run()
+	if err != nil {
+		logger.Criticalf("can not apply suggestions: %s", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d suggestion(s) successfully applied\n", len(accepted))
+}
+
+func askYesNo(question string, byDefault bool) bool {
+	hint := "[Yn]"
+	if !byDefault {
+		hint = "[yN]"
+	}
+
+	for {
+		fmt.Printf("%s %s ", question, hint)
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return false
+		}
+
+		switch answer {
+		case "\n":
+			return byDefault
+		case "n\n", "N\n":
+			return false
+		case "y\n", "Y\n":
+			return true
+		}
+	}
+}
+
+func repoMode(args map[string]interface{}, repo Repo, config Config) {
 	switch {
 	case args["ls-reviews"]:
 		state := "open"
@@ -369,34 +913,360 @@ func repoMode(args map[string]interface{}, repo Repo) {
 		case args["merged"]:
 			state = "merged"
 		}
-		showReviewsInRepo(repo, state, args["-d"].(bool))
+		showReviewsInRepo(
+			repo, config, state, args["-d"].(bool),
+			getPullRequestFilter(args),
+		)
+	case args["create"]:
+		editor := os.Getenv("EDITOR")
+		if args["-e"] != nil {
+			editor = args["-e"].(string)
+		}
+
+		target := ""
+		if args["<target-branch>"] != nil {
+			target = args["<target-branch>"].(string)
+		}
+
+		createPullRequest(
+			repo, config, editor,
+			args["<branch>"].(string), target,
+			getReviewersArg(args),
+		)
+	}
+}
+
+func getReviewersArg(args map[string]interface{}) []string {
+	if args["--reviewers"] == nil {
+		return []string{}
+	}
+
+	return parseList(args["--reviewers"].(string))
+}
+
+func projectMode(args map[string]interface{}, project Project) {
+	switch {
+	case args["ls-repos"]:
+		showRepos(args, project)
+	}
+}
+
+func searchMode(args map[string]interface{}, project Project) {
+	search := PullRequestSearch{
+		State: args["--state"].(string),
+	}
+
+	if args["<text>"] != nil {
+		search.Text = args["<text>"].(string)
+	}
+
+	if args["--author"] != nil {
+		search.Author = args["--author"].(string)
+	}
+
+	if args["--participant"] != nil {
+		search.Participant = args["--participant"].(string)
+	}
+
+	if args["--target-branch"] != nil {
+		search.TargetBranch = args["--target-branch"].(string)
+	}
+
+	_, limit := getListArgs(args)
+
+	prs, err := project.SearchPullRequests(search, limit)
+	if err != nil {
+		logger.Criticalf("can not search pull requests: %s", err.Error())
+		os.Exit(1)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+
+	for _, pr := range prs {
+		printPullRequest(writer, pr, args["-d"].(bool), true)
+	}
+
+	writer.Flush()
+}
+
+func showProjects(args map[string]interface{}, api Api) {
+	filter, limit := getListArgs(args)
+
+	projects, err := api.ListProjects(filter, limit)
+	if err != nil {
+		logger.Criticalf("can not list projects: %s", err.Error())
+		os.Exit(1)
+	}
+
+	if args["--json"].(bool) {
+		printJSON(projects)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+
+	for _, project := range projects {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n",
+			strings.ToLower(project.Key), project.Name,
+			strings.Split(project.Description, "\n")[0],
+		)
+	}
+
+	writer.Flush()
+}
+
+func showRepos(args map[string]interface{}, project Project) {
+	filter, limit := getListArgs(args)
+
+	repos, err := project.ListRepos(filter, limit)
+	if err != nil {
+		logger.Criticalf("can not list repos: %s", err.Error())
+		os.Exit(1)
+	}
+
+	if args["--json"].(bool) {
+		printJSON(repos)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+
+	for _, repo := range repos {
+		fmt.Fprintf(writer, "%s/%s\t%s\n",
+			strings.ToLower(repo.Project.Key), repo.Slug,
+			repo.GetCloneURL("ssh"),
+		)
+	}
+
+	writer.Flush()
+}
+
+func getListArgs(args map[string]interface{}) (string, int) {
+	filter := ""
+	if args["<filter>"] != nil {
+		filter = args["<filter>"].(string)
+	}
+
+	limit := 0
+	if args["--limit"] != nil {
+		var err error
+		limit, err = strconv.Atoi(args["--limit"].(string))
+		if err != nil {
+			fmt.Println("--limit should be a number.")
+			os.Exit(1)
+		}
+	}
+
+	return filter, limit
+}
+
+func printJSON(value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	fmt.Println(string(data))
+}
+
+func showReviewsInRepo(
+	repo Repo, config Config, state string, withDesc bool,
+	filter PullRequestFilter,
+) {
+	reviews, err := repo.ListPullRequest(state)
+
+	if err != nil {
+		logger.Criticalf("can not list reviews: %s", err.Error())
+	}
+
+	reviews = filter.Apply(reviews)
+
+	conditions := []DefaultReviewersCondition{}
+	if withDesc {
+		conditions, err = repo.GetDefaultReviewersConditions()
+		if err != nil {
+			logger.Warning(
+				"can not get default reviewers conditions: %s", err.Error(),
+			)
+		}
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+
+	for _, r := range reviews {
+		printPullRequest(writer, r, withDesc, true)
+
+		if withDesc {
+			printMissingReviewers(writer, r, MatchDefaultReviewers(
+				config.GetReviewers(repo.Project.Key(), repo.Name),
+				conditions, r.FromRef.Id, r.ToRef.Id,
+			))
+		}
+	}
+
+	writer.Flush()
+}
+
+func printMissingReviewers(
+	writer io.Writer, pr PullRequest, defaultReviewers []string,
+) {
+	added := map[string]bool{pr.Author.User.Name: true}
+	for _, reviewer := range pr.Reviewers {
+		added[reviewer.User.Name] = true
+	}
+
+	missing := []string{}
+	for _, name := range defaultReviewers {
+		if !added[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(
+			writer, "default reviewers not added: %s\n",
+			strings.Join(missing, " "),
+		)
+	}
+}
+
+func createPullRequest(
+	repo Repo, config Config, editor string,
+	branch string, target string,
+	extraReviewers []string,
+) {
+	fromRef := branch
+	if !strings.HasPrefix(fromRef, "refs/") {
+		fromRef = "refs/heads/" + fromRef
+	}
+
+	toRef := target
+	if toRef == "" {
+		var err error
+		toRef, err = repo.GetDefaultBranch()
+		if err != nil {
+			logger.Criticalf("can not get default branch: %s", err.Error())
+			os.Exit(1)
+		}
+	} else if !strings.HasPrefix(toRef, "refs/") {
+		toRef = "refs/heads/" + toRef
+	}
+
+	reviewers := withoutUser(
+		uniqueStrings(append(
+			repo.GetDefaultReviewers(config, fromRef, toRef),
+			extraReviewers...,
+		)),
+		repo.Auth.Username,
+	)
+
+	message := strings.TrimPrefix(fromRef, "refs/heads/") + "\n\n" +
+		ReadTemplate(config, repo.Project.Key(), repo.Name)
+
+	if editor != "" {
+		message = editPullRequestMessage(editor, message, fmt.Sprintf(
+			"### Empty title aborts creation.\n"+
+				"### %s -> %s, reviewers: %s\n",
+			fromRef, toRef, strings.Join(reviewers, " "),
+		))
+	}
+
+	title, description := ParsePullRequestMessage(message)
+	if title == "" {
+		fmt.Println("Title is empty, pull request is not created.")
+		os.Exit(1)
+	}
+
+	pr, err := repo.CreatePullRequest(
+		title, description, fromRef, toRef, reviewers,
+	)
+	if err != nil {
+		logger.Criticalf("can not create pull request: %s", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(pr.URL())
+}
+
+// editPullRequest changes title and description of pull request in editor
+// and adds default reviewers, which are missing, to it.
+func editPullRequest(
+	pr PullRequest, config Config, editor string, extraReviewers []string,
+) {
+	info, err := pr.GetInfo()
+	if err != nil {
+		logger.Criticalf("can not get pull request: %s", err.Error())
+		os.Exit(1)
+	}
+
+	reviewers := append(info.GetReviewerNames(), pr.Repo.GetDefaultReviewers(
+		config, info.FromRef.Id, info.ToRef.Id,
+	)...)
+
+	reviewers = withoutUser(
+		uniqueStrings(append(reviewers, extraReviewers...)),
+		info.Author.User.Name,
+	)
+
+	description := info.Description
+	if strings.TrimSpace(description) == "" {
+		description = ReadTemplate(config, pr.Project.Key(), pr.Repo.Name)
+	}
+
+	message := info.Title + "\n\n" + description
+
+	if editor != "" {
+		message = editPullRequestMessage(editor, message, fmt.Sprintf(
+			"### Empty title aborts editing.\n"+
+				"### %s -> %s, reviewers: %s\n",
+			info.FromRef.Id, info.ToRef.Id, strings.Join(reviewers, " "),
+		))
+	}
+
+	title, description := ParsePullRequestMessage(message)
+	if title == "" {
+		fmt.Println("Title is empty, pull request is not changed.")
+		os.Exit(1)
+	}
+
+	err = pr.Update(info.Version, title, description, reviewers)
+	if err != nil {
+		logger.Criticalf("can not update pull request: %s", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(pr.URL())
+}
+
+func editPullRequestMessage(editor string, message string, hint string) string {
+	messageFile, err := ioutil.TempFile(tmpWorkDir, "pull-request.")
+	if err != nil {
+		logger.Fatal(err)
 	}
-}
 
-func showReviewsInRepo(repo Repo, state string, withDesc bool) {
-	reviews, err := repo.ListPullRequest(state)
+	fmt.Fprintf(messageFile,
+		"%s\n"+
+			"### First line is the title, the rest is the description.\n%s",
+		message, hint,
+	)
+
+	messageFile.Close()
 
+	err = runEditor(editor, messageFile.Name())
 	if err != nil {
-		logger.Criticalf("can not list reviews: %s", err.Error())
+		logger.Fatal(err)
 	}
 
-	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
-
-	for _, r := range reviews {
-		printPullRequest(writer, r, withDesc, true)
+	data, err := ioutil.ReadFile(messageFile.Name())
+	if err != nil {
+		logger.Fatal(err)
 	}
 
-	writer.Flush()
+	return string(data)
 }
 
 func printPullRequest(writer io.Writer, pr PullRequest, withDesc bool, printStatus bool) {
-	slug := fmt.Sprintf("%s/%s/%d",
-		strings.ToLower(pr.ToRef.Repository.Project.Key),
-		pr.ToRef.Repository.Slug,
-		pr.Id,
-	)
-
-	fmt.Fprintf(writer, "%-30s", slug)
+	fmt.Fprintf(writer, "%-30s", getPullRequestSlug(pr))
 
 	refSegments := strings.Split(pr.FromRef.Id, "/")
 	branchName := refSegments[len(refSegments)-1]
@@ -481,6 +1351,12 @@ func parseUri(args map[string]interface{}) (
 		should = 2
 	}
 
+	if args["<project>"] != nil {
+		keyName = "<project>"
+		uri = args[keyName].(string)
+		should = 1
+	}
+
 	matches := reStashURL.FindStringSubmatch(uri)
 	if len(matches) != 0 {
 		result.base = matches[1]
@@ -515,6 +1391,10 @@ func parseUri(args map[string]interface{}) (
 		result.project = args["--project"].(string)
 	}
 
+	if len(matches) == 1 && should == 1 {
+		result.project = matches[0]
+	}
+
 	if len(matches) == 1 && should == 2 {
 		result.repo = matches[0]
 	}
@@ -530,8 +1410,9 @@ func parseUri(args map[string]interface{}) (
 		result.pr, _ = strconv.ParseInt(matches[2], 10, 16)
 	}
 
-	enough := result.project != "" && result.repo != "" &&
-		(result.pr != 0 || should == 2)
+	enough := result.project != "" &&
+		(result.repo != "" || should == 1) &&
+		(result.pr != 0 || should < 3)
 
 	if !enough {
 		fmt.Println(
@@ -561,13 +1442,7 @@ func editReviewInEditor(
 		os.Exit(0)
 	}
 
-	logger.Debug("opening editor: %s %s", editor, fileToUse.Name())
-	editorCmd := exec.Command(editor, fileToUse.Name())
-	editorCmd.Stdin = os.Stdin
-	editorCmd.Stdout = os.Stdout
-	editorCmd.Stderr = os.Stderr
-
-	err := editorCmd.Run()
+	err := runEditor(editor, fileToUse.Name())
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -585,29 +1460,130 @@ func editReviewInEditor(
 	return reviewToEdit.Compare(editedReview), nil
 }
 
-func mergeArgsWithConfig(path string) []string {
-	args := make([]string, 0)
+func runEditor(editor string, path string) error {
+	logger.Debug("opening editor: %s %s", editor, path)
+	editorCmd := exec.Command(editor, path)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+
+	return editorCmd.Run()
+}
 
+func loadConfig(path string) Config {
 	conf, err := ioutil.ReadFile(path)
-
 	if err != nil {
 		logger.Warning("can not access config: %s", err.Error())
-	} else {
-		confLines := strings.Split(string(conf), "\n")
-		for _, line := range confLines {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
-			args = append(args, line)
+		return Config{}
+	}
+
+	config, err := LoadConfig(string(conf))
+	if err != nil {
+		fmt.Printf("Config %s is invalid: %s\n", path, err.Error())
+		os.Exit(1)
+	}
+
+	return config
+}
+
+func mergeArgsWithConfig(config Config, cmdLine []string) []string {
+	// pull request of review file is not given in the command line, so it's
+	// taken from the modeline to select profile by its URL
+	urls := []string{}
+	if reviewFile := getReviewFileArg(cmdLine); reviewFile != "" {
+		if modeline := readAshModeline(reviewFile); modeline != nil {
+			urls = append(urls, modeline.URL)
+		}
+	}
+
+	profile := config.SelectProfile(append(cmdLine, urls...))
+	if profile != "" {
+		if _, ok := config.Profiles[profile]; !ok {
+			logger.Warning("profile '%s' is not found in config", profile)
 		}
 	}
 
-	args = append(args, os.Args[1:]...)
+	args := config.GetArgs(profile)
+	args = append(args, cmdLine...)
 
 	return args
 }
 
+// getReviewFileArg returns review file given to 'apply' command or to the
+// --refresh flag.
+func getReviewFileArg(cmdLine []string) string {
+	for i, arg := range cmdLine {
+		switch {
+		case strings.HasPrefix(arg, "--refresh="):
+			return strings.TrimPrefix(arg, "--refresh=")
+		case (arg == "apply" || arg == "--refresh") && i+1 < len(cmdLine):
+			return cmdLine[i+1]
+		}
+	}
+
+	return ""
+}
+
+// applyScopedConfig sets values of project and repo config sections, unless
+// they are specified in the command line.
+// applyScopedConfig sets flags from project and repo config sections unless
+// they are specified in the command line, which is expected to have aliases
+// expanded already.
+func applyScopedConfig(
+	args map[string]interface{}, cmdLine []string,
+	config Config, project string, repo string,
+) {
+	for key, value := range config.GetScoped(project, repo) {
+		flag := configKeys[key].flag
+		if flag == "" || isFlagInCmdLine(flag, cmdLine) {
+			continue
+		}
+
+		logger.Debug("using %s = %s from config of %s/%s",
+			key, value, project, repo)
+
+		if configKeys[key].kind == configBool {
+			args[flag], _ = strconv.ParseBool(value)
+		} else {
+			args[flag] = value
+		}
+	}
+}
+
+func isFlagInCmdLine(flag string, cmdLine []string) bool {
+	withValues := getOptionsWithValue(usage)
+
+	for i := 0; i < len(cmdLine); i++ {
+		arg := cmdLine[i]
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+
+		if strings.HasPrefix(flag, "--") || strings.HasPrefix(arg, "--") ||
+			!strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		// short flags can be combined like -iw, and the rest of argument
+		// after short option with value, like -evim, is its value
+		for j, letter := range arg[1:] {
+			if "-"+string(letter) == flag {
+				return true
+			}
+
+			if withValues["-"+string(letter)] {
+				if j == len(arg)-2 {
+					i++
+				}
+
+				break
+			}
+		}
+	}
+
+	return false
+}
+
 func showFilesList(pr PullRequest) {
 	logger.Debug("showing list of files in PR")
 	files, err := pr.GetFiles()
@@ -632,21 +1608,40 @@ func showFilesList(pr PullRequest) {
 func review(
 	pr PullRequest, editor string,
 	path string,
-	origin string, input string, output string,
+	origin string, input string, applyMode bool,
+	output string, refresh string,
 	activitiesLimit string,
+	activityFilter ActivityFilter,
+	inlineCommits bool,
 	ignoreWhitespaces bool,
+	contextLines string,
 	interactiveMode bool,
+	wrapWidth int,
+	removalGuard RemovalGuard,
+	offline bool,
 ) {
 	var review *Review
 	var err error
 
+	if offline && origin == "" {
+		origin = getCachedReviewPath(&pr, path)
+		if _, err := os.Stat(origin); err != nil {
+			fmt.Println(
+				"Review is not cached, use 'fetch' command while online.",
+			)
+			os.Exit(1)
+		}
+	}
+
 	if origin == "" {
 		if path == "" {
 			logger.Debug("downloading overview from Stash")
-			review, err = pr.GetActivities(activitiesLimit)
+			review, err = pr.GetActivities(
+				activitiesLimit, activityFilter, inlineCommits,
+			)
 		} else {
 			logger.Debug("downloading review from Stash")
-			review, err = pr.GetReview(path, ignoreWhitespaces)
+			review, err = pr.GetReview(path, ignoreWhitespaces, contextLines)
 		}
 
 		if review == nil {
@@ -654,10 +1649,29 @@ func review(
 			os.Exit(1)
 		}
 
+		if path == "" {
+			// without header changes of description would be lost
+			info, err := pr.GetInfo()
+			if err != nil {
+				logger.Criticalf(
+					"can not get pull request info: %s", err.Error(),
+				)
+				os.Exit(1)
+			}
+
+			review.header = NewReviewHeader(info)
+		}
+
+		if len(review.changeset.Diffs) == 0 && !activityFilter.IsEmpty() {
+			fmt.Println("No activities match specified filters.")
+			os.Exit(1)
+		}
+
 		if len(review.changeset.Diffs) == 0 {
 			fmt.Println("Specified file is not found in pull request.")
 			os.Exit(1)
 		}
+
 	} else {
 		logger.Debug("using origin review from file %s", origin)
 		originFile, err := os.Open(origin)
@@ -681,6 +1695,26 @@ func review(
 		logger.Fatal(err)
 	}
 
+	review.wrapWidth = wrapWidth
+
+	reviewToWrite := review
+	if refresh != "" && input == "" {
+		logger.Debug("refreshing review file %s", refresh)
+		localFile, err := os.Open(refresh)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		localReview, err := ReadReview(localFile)
+		localFile.Close()
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		reviewToWrite = review.Refresh(localReview)
+		output = refresh
+	}
+
 	var changes []ReviewChange
 	var fileToUse *os.File
 
@@ -705,13 +1739,38 @@ func review(
 			panic(err)
 		}
 
+		// review file given to apply command can be written long ago, so
+		// comments posted since then should not be considered deleted
+		if applyMode && origin == "" {
+			review.ForgetNewerComments(editedReview)
+		}
+
 		logger.Debug("comparing old and new reviews")
 		changes = review.Compare(editedReview)
 	} else {
-		pullRequestInfo, err := pr.GetInfo()
-		if err != nil {
-			fmt.Println("Error while obtaining pull request info: %s", err)
-			os.Exit(1)
+		reviewURL := pr.URL()
+
+		if !offline {
+			pullRequestInfo, err := pr.GetInfo()
+			if err != nil {
+				fmt.Println("Error while obtaining pull request info: %s", err)
+				os.Exit(1)
+			}
+
+			reviewURL = pullRequestInfo.Links.Self[0].Href
+
+			var files ReviewFiles
+			if path == "" {
+				files, err = pr.GetFilesStats()
+			} else {
+				files, err = pr.GetFiles()
+			}
+
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			reviewToWrite.AddComment(files.String())
 		}
 
 		printFileName := false
@@ -723,20 +1782,11 @@ func review(
 			writeAndExit = true
 			printFileName = true
 			output = tmpWorkDir + "/review.diff"
-		} else {
+		} else if refresh == "" {
 			writeAndExit = true
 		}
 
-		files, err := pr.GetFiles()
-		if err != nil {
-			logger.Fatal(err)
-		}
-
-		review.AddComment(files.String())
-
-		fileToUse, err = WriteReviewToFile(
-			pullRequestInfo.Links.Self[0].Href, review, output,
-		)
+		fileToUse, err = WriteReviewToFile(reviewURL, reviewToWrite, output)
 
 		if err != nil {
 			logger.Fatal(err)
@@ -761,6 +1811,97 @@ func review(
 		os.Exit(2)
 	}
 
+	if offline {
+		err = QueueToOutbox(
+			&pr, path, origin, fileToUse.Name(), wrapWidth,
+		)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		fmt.Printf(
+			"%d change(s) queued to outbox, use 'sync' command to send\n",
+			len(changes),
+		)
+
+		return
+	}
+
+	err = applyChanges(pr, changes, editor, interactiveMode, removalGuard)
+	switch err.(type) {
+	case nil:
+	case changesRejected:
+		abortReview(fileToUse.Name())
+	default:
+		fmt.Printf(
+			"\n%s. Review file is kept at:\n\t%s\n",
+			err.Error(), fileToUse.Name(),
+		)
+
+		os.Exit(1)
+	}
+}
+
+// changesRejected is returned by applyChanges if nothing is applied because
+// changes are invalid or are not confirmed by user.
+type changesRejected struct{}
+
+func (changesRejected) Error() string {
+	return "changes are rejected"
+}
+
+type changesNotApplied struct {
+	failed int
+	total  int
+}
+
+func (err changesNotApplied) Error() string {
+	return fmt.Sprintf(
+		"%d of %d change(s) can not be applied", err.failed, err.total,
+	)
+}
+
+func applyChanges(
+	pr PullRequest, changes []ReviewChange,
+	editor string, interactiveMode bool,
+	removalGuard RemovalGuard,
+) error {
+	currentUser := pr.Auth.Username
+
+	logger.Debug("resolving current user")
+	user, err := pr.GetUser(pr.Auth.Username)
+	if err != nil {
+		logger.Warning("can not resolve current user: %s", err.Error())
+	} else {
+		currentUser = user.Name
+	}
+
+	// comments read from review file have no author login, only display name
+	err = pr.ResolveCommentAuthors(changes)
+	if err != nil {
+		logger.Warning("can not resolve authors of comments: %s", err.Error())
+	}
+
+	if illegal := ValidateChanges(changes, currentUser); len(illegal) > 0 {
+		fmt.Println("Following changes can not be applied:")
+		for _, err := range illegal {
+			fmt.Printf("\n%s\n", err.Error())
+		}
+
+		return changesRejected{}
+	}
+
+	if removals := removalGuard.Check(changes); len(removals) > 0 {
+		fmt.Println("Following comments are going to be deleted:")
+		for _, removal := range removals {
+			fmt.Printf("\n%s\n", removal.Details())
+		}
+
+		if !askYesNo("\n---\nDo you really want to delete them?", false) {
+			return changesRejected{}
+		}
+	}
+
 	if interactiveMode {
 		for i, change := range changes {
 			fmt.Printf("%d. %s\n\n", i+1, change.String())
@@ -773,7 +1914,7 @@ func review(
 
 			switch answer {
 			case "n\n", "N\n":
-				os.Exit(2)
+				return changesRejected{}
 			case "\n", "Y\n":
 				pendingAnswer = false
 			}
@@ -782,14 +1923,193 @@ func review(
 
 	logger.Debug("applying changes (%d)", len(changes))
 
+	failed := 0
 	for i, change := range changes {
 		fmt.Printf("(%d/%d) applying changes\n", i+1, len(changes))
 		logger.Debug("change payload: %#v", change.GetPayload())
 		err := pr.ApplyChange(change)
+		if conflict, ok := err.(commentConflict); ok {
+			logger.Warning("%s, trying to resolve", conflict.Error())
+			err = resolveConflict(pr, change, conflict.remote, editor)
+		}
+
 		if err != nil {
 			logger.Criticalf("can not apply change: %s", err.Error())
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return changesNotApplied{failed, len(changes)}
+	}
+
+	return nil
+}
+
+func fetch(
+	pr PullRequest, path string,
+	activitiesLimit string, ignoreWhitespaces bool, contextLines string,
+) {
+	paths := []string{path}
+
+	if path == "" {
+		files, err := pr.GetFiles()
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		for _, file := range files {
+			if file.ChangeType != "DELETE" {
+				paths = append(paths, file.DstPath)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		var review *Review
+		var err error
+
+		if path == "" {
+			logger.Debug("downloading overview from Stash")
+			review, err = pr.GetActivities(
+				activitiesLimit, ActivityFilter{}, false,
+			)
+		} else {
+			logger.Debug("downloading review of %s from Stash", path)
+			review, err = pr.GetReview(path, ignoreWhitespaces, contextLines)
+		}
+
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		err = cacheReview(&pr, path, review)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	fmt.Printf("%d review(s) fetched for offline use\n", len(paths))
+}
+
+func syncMode(args map[string]interface{}, auth gopencils.BasicAuth) {
+	entries, err := ListOutbox()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Outbox is empty.")
+		return
+	}
+
+	editor := os.Getenv("EDITOR")
+	if args["-e"] != nil {
+		editor = args["-e"].(string)
+	}
+
+	maxRemovals, err := strconv.Atoi(args["--max-removals"].(string))
+	if err != nil {
+		fmt.Println("--max-removals should be a number.")
+		os.Exit(1)
+	}
+
+	removalGuard := RemovalGuard{
+		MaxRemovals: maxRemovals,
+		Force:       args["--force"].(bool),
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		fmt.Printf("syncing %s %s\n", entry.URL, entry.Path)
+
+		err := syncOutboxEntry(
+			entry, auth, getResponseCache(args),
+			editor, args["-i"].(bool), removalGuard,
+		)
+
+		switch err.(type) {
+		case nil:
+		case changesNotApplied:
+			// some changes are sent already, so entry can not be retried
+			// without sending them twice
+			fmt.Printf("%s, see log above for details\n", err.Error())
+			failed++
+		default:
+			fmt.Printf(
+				"can not sync: %s; changes are kept in outbox\n",
+				err.Error(),
+			)
+			failed++
+			continue
+		}
+
+		err = entry.Remove()
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d outbox entries are not synced\n",
+			failed, len(entries))
+		os.Exit(1)
+	}
+}
+
+func syncOutboxEntry(
+	entry OutboxEntry, auth gopencils.BasicAuth, cache *ResponseCache,
+	editor string, interactiveMode bool, removalGuard RemovalGuard,
+) error {
+	matches := reStashURL.FindStringSubmatch(entry.URL)
+	if len(matches) == 0 {
+		return fmt.Errorf("invalid pull request url: %s", entry.URL)
+	}
+
+	api := Api{strings.TrimSuffix(matches[1], "/"), auth, nil, cache}
+	project := Project{&api, matches[2]}
+	repo := project.GetRepo(matches[5])
+	id, _ := strconv.ParseInt(matches[6], 10, 64)
+	pr := repo.GetPullRequest(id)
+
+	origin, err := entry.ReadOrigin()
+	if err != nil {
+		return err
+	}
+
+	edited, err := entry.ReadReview()
+	if err != nil {
+		return err
+	}
+
+	origin.wrapWidth = entry.WrapWidth
+
+	if entry.Path == "" {
+		origin.isOverview = true
+	} else {
+		actual, err := pr.GetReview(entry.Path, false, "")
+		if err != nil {
+			return err
 		}
+
+		actual.RebaseAnchors(edited)
 	}
+
+	changes := origin.Compare(edited)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return applyChanges(pr, changes, editor, interactiveMode, removalGuard)
+}
+
+func abortReview(reviewFileName string) {
+	fmt.Printf(
+		"\nNothing is applied. Review file is kept at:\n\t%s\n",
+		reviewFileName,
+	)
+
+	os.Exit(2)
 }
 
 func WriteReviewToFile(
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+)
+
+type textHunk struct {
+	start int
+	end   int
+	lines []string
+}
+
+// MergeText performs line-based three-way merge of two texts derived from
+// the same base. It returns false if both texts change the same lines.
+func MergeText(base, ours, theirs string) (string, bool) {
+	switch {
+	case ours == theirs, theirs == base:
+		return ours, true
+	case ours == base:
+		return theirs, true
+	}
+
+	baseLines := strings.Split(base, "\n")
+
+	ourHunks := diffLines(baseLines, strings.Split(ours, "\n"))
+	theirHunks := diffLines(baseLines, strings.Split(theirs, "\n"))
+
+	hunks := []textHunk{}
+	for len(ourHunks) > 0 || len(theirHunks) > 0 {
+		switch {
+		case len(theirHunks) == 0:
+			hunks = append(hunks, ourHunks...)
+			ourHunks = nil
+		case len(ourHunks) == 0:
+			hunks = append(hunks, theirHunks...)
+			theirHunks = nil
+		case reflect.DeepEqual(ourHunks[0], theirHunks[0]):
+			hunks = append(hunks, ourHunks[0])
+			ourHunks = ourHunks[1:]
+			theirHunks = theirHunks[1:]
+		case ourHunks[0].start <= theirHunks[0].end &&
+			theirHunks[0].start <= ourHunks[0].end:
+			return "", false
+		case ourHunks[0].start < theirHunks[0].start:
+			hunks = append(hunks, ourHunks[0])
+			ourHunks = ourHunks[1:]
+		default:
+			hunks = append(hunks, theirHunks[0])
+			theirHunks = theirHunks[1:]
+		}
+	}
+
+	result := []string{}
+	position := 0
+	for _, hunk := range hunks {
+		result = append(result, baseLines[position:hunk.start]...)
+		result = append(result, hunk.lines...)
+		position = hunk.end
+	}
+
+	result = append(result, baseLines[position:]...)
+
+	return strings.Join(result, "\n"), true
+}
+
+// diffLines returns list of hunks, each of them replaces lines of base
+// in range [start; end) with specified lines to get changed text.
+func diffLines(base, changed []string) []textHunk {
+	common := make([][]int, len(base)+1)
+	for i := range common {
+		common[i] = make([]int, len(changed)+1)
+	}
+
+	for i := len(base) - 1; i >= 0; i-- {
+		for j := len(changed) - 1; j >= 0; j-- {
+			switch {
+			case base[i] == changed[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] > common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	hunks := []textHunk{}
+	var hunk *textHunk
+
+	i, j := 0, 0
+	for i < len(base) || j < len(changed) {
+		if i < len(base) && j < len(changed) && base[i] == changed[j] {
+			if hunk != nil {
+				hunks = append(hunks, *hunk)
+				hunk = nil
+			}
+
+			i++
+			j++
+			continue
+		}
+
+		if hunk == nil {
+			hunk = &textHunk{start: i, end: i, lines: []string{}}
+		}
+
+		if j < len(changed) &&
+			(i == len(base) || common[i][j+1] >= common[i+1][j]) {
+			hunk.lines = append(hunk.lines, changed[j])
+			j++
+		} else {
+			i++
+			hunk.end = i
+		}
+	}
+
+	if hunk != nil {
+		hunks = append(hunks, *hunk)
+	}
+
+	return hunks
+}
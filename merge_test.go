@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMergeText(t *testing.T) {
+	tests := []struct {
+		base     string
+		ours     string
+		theirs   string
+		expected string
+		merged   bool
+	}{
+		{
+			"a\nb\nc",
+			"a\nB\nc",
+			"a\nb\nc",
+			"a\nB\nc",
+			true,
+		},
+		{
+			"a\nb\nc\nd\ne",
+			"A\nb\nc\nd\ne",
+			"a\nb\nc\nd\nE",
+			"A\nb\nc\nd\nE",
+			true,
+		},
+		{
+			"a\nb\nc\nd\ne",
+			"a\nb\nc\nd\ne\nf",
+			"x\na\nb\nc\nd\ne",
+			"x\na\nb\nc\nd\ne\nf",
+			true,
+		},
+		{
+			"a\nb\nc",
+			"a\nB\nc",
+			"a\nX\nc",
+			"",
+			false,
+		},
+		{
+			"a\nb\nc",
+			"a\nB\nc",
+			"a\nB\nc",
+			"a\nB\nc",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		actual, merged := MergeText(test.base, test.ours, test.theirs)
+		if merged != test.merged || actual != test.expected {
+			t.Fatalf("unexpected merge result\n%q %v\n%q %v",
+				test.expected, test.merged, actual, merged)
+		}
+	}
+}
+
+func TestMergeWrappedText(t *testing.T) {
+	base := "aaa bbb ccc\nddd eee fff\n\nsecond"
+	theirs := "AAA bbb ccc\nddd eee fff\n\nsecond"
+
+	ours := normalizeCommentText(
+		WrapText("aaa bbb ccc\nddd eee fff\n\n2nd", 10), 10,
+	)
+
+	actual, merged := MergeText(
+		normalizeCommentText(base, 10), ours,
+		normalizeCommentText(theirs, 10),
+	)
+
+	expected := "AAA bbb ccc ddd eee fff\n\n2nd"
+	if !merged || actual != expected {
+		t.Fatalf("unexpected merge result\n%q\n%q %v", expected, actual, merged)
+	}
+}
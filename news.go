@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SeenActivities maps pull request URL to the id of the newest activity
+// shown by 'news' command.
+type SeenActivities map[string]int64
+
+const (
+	// newsLimit bounds number of activities requested to count news in inbox.
+	newsLimit = 100
+
+	// newsRequests is number of simultaneous requests to count news in inbox.
+	newsRequests = 8
+)
+
+// Get returns id of the newest seen activity of pull request. Project key is
+// upper-cased in inbox and lower-cased in command line, so URL is compared
+// case-insensitively.
+func (seen SeenActivities) Get(pr *PullRequest) int64 {
+	return seen[strings.ToLower(pr.URL())]
+}
+
+func (seen SeenActivities) Set(pr *PullRequest, id int64) {
+	seen[strings.ToLower(pr.URL())] = id
+}
+
+func getSeenActivitiesPath() string {
+	return filepath.Join(cachePath, "seen.json")
+}
+
+func LoadSeenActivities() SeenActivities {
+	seen := SeenActivities{}
+
+	data, err := ioutil.ReadFile(getSeenActivitiesPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("can not read seen activities: %s", err.Error())
+		}
+
+		return seen
+	}
+
+	err = json.Unmarshal(data, &seen)
+	if err != nil {
+		logger.Warning("can not read seen activities: %s", err.Error())
+	}
+
+	return seen
+}
+
+func (seen SeenActivities) Save() error {
+	data, err := json.MarshalIndent(seen, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(cachePath, 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(getSeenActivitiesPath(), data, 0600)
+}
+
+// getInboxPullRequest returns pull request from inbox bound to the API, so
+// it can be accessed like pull requests specified in the command line.
+func getInboxPullRequest(api *Api, pr PullRequest) PullRequest {
+	projectName := "projects/" + pr.ToRef.Repository.Project.Key
+	if strings.HasPrefix(pr.ToRef.Repository.Project.Key, "~") {
+		projectName = "users/" + pr.ToRef.Repository.Project.Key[1:]
+	}
+
+	project := Project{api, projectName}
+	repo := project.GetRepo(pr.ToRef.Repository.Slug)
+
+	return repo.GetPullRequest(pr.Id)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReviewActivitySince(t *testing.T) {
+	activity := ReviewActivity{Filter: ActivityFilter{SinceId: 2}}
+
+	err := json.Unmarshal([]byte(`[
+		{"id": 3, "action": "APPROVED", "user": {"displayName": "Bob"}},
+		{"id": 2, "action": "APPROVED", "user": {"displayName": "Alice"}},
+		{"id": 1, "action": "OPENED", "user": {"displayName": "Alice"}}
+	]`), &activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if activity.LastId != 3 || activity.Count != 1 {
+		t.Fatalf("unexpected last id %d and count %d",
+			activity.LastId, activity.Count)
+	}
+
+	if len(activity.Diffs) != 1 {
+		t.Fatalf("only one activity expected, got %d", len(activity.Diffs))
+	}
+}
+
+func TestSeenActivitiesIgnoreProjectCase(t *testing.T) {
+	api := &Api{URL: "http://stash.local"}
+
+	inbox := PullRequest{
+		Repo: &Repo{Project: &Project{api, "projects/PROJ"}, Name: "repo"},
+		Id:   1,
+	}
+
+	shorthand := PullRequest{
+		Repo: &Repo{Project: &Project{api, "projects/proj"}, Name: "repo"},
+		Id:   1,
+	}
+
+	seen := SeenActivities{}
+	seen.Set(&shorthand, 10)
+
+	if since := seen.Get(&inbox); since != 10 {
+		t.Fatalf("expected seen activity 10, got %d", since)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/seletskiy/godiff"
+)
+
+type OutboxEntry struct {
+	URL     string
+	Path    string
+	Created time.Time
+
+	// WrapWidth is width, which comments of review were wrapped to, so they
+	// are reflowed the same way on sync.
+	WrapWidth int
+
+	dir string
+}
+
+func getCachedReviewPath(pr *PullRequest, path string) string {
+	host := "unknown"
+	if hostURL, err := url.Parse(pr.Project.URL); err == nil {
+		host = hostURL.Host
+	}
+
+	name := "overview.diff"
+	if path != "" {
+		name = url.QueryEscape(path) + ".diff"
+	}
+
+	return filepath.Join(
+		cachePath, "reviews", host,
+		pr.Project.Name, pr.Repo.Name, fmt.Sprint(pr.Id),
+		name,
+	)
+}
+
+func cacheReview(pr *PullRequest, path string, review *Review) error {
+	cachedPath := getCachedReviewPath(pr, path)
+
+	err := os.MkdirAll(filepath.Dir(cachedPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(cachedPath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	logger.Debug("caching review to %s", cachedPath)
+
+	return WriteReview(review, file)
+}
+
+func QueueToOutbox(
+	pr *PullRequest, path string, originPath string, reviewPath string,
+	wrapWidth int,
+) error {
+	entry := OutboxEntry{
+		URL:       pr.URL(),
+		Path:      path,
+		Created:   time.Now(),
+		WrapWidth: wrapWidth,
+	}
+
+	entry.dir = filepath.Join(
+		cachePath, "outbox", fmt.Sprint(entry.Created.UnixNano()),
+	)
+
+	err := os.MkdirAll(entry.dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	for source, target := range map[string]string{
+		originPath: "origin.diff",
+		reviewPath: "review.diff",
+	} {
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(entry.dir, target), data, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("change queued to outbox %s", entry.dir)
+
+	return ioutil.WriteFile(filepath.Join(entry.dir, "entry.json"), data, 0600)
+}
+
+func ListOutbox() ([]OutboxEntry, error) {
+	dirs, err := filepath.Glob(filepath.Join(cachePath, "outbox", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(dirs)
+
+	entries := []OutboxEntry{}
+	for _, dir := range dirs {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "entry.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		entry := OutboxEntry{dir: dir}
+		err = json.Unmarshal(data, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (entry OutboxEntry) ReadOrigin() (*Review, error) {
+	return readReviewFile(filepath.Join(entry.dir, "origin.diff"))
+}
+
+func (entry OutboxEntry) ReadReview() (*Review, error) {
+	return readReviewFile(filepath.Join(entry.dir, "review.diff"))
+}
+
+func (entry OutboxEntry) ReviewFileName() string {
+	return filepath.Join(entry.dir, "review.diff")
+}
+
+func (entry OutboxEntry) Remove() error {
+	return os.RemoveAll(entry.dir)
+}
+
+// RebaseAnchors moves new line comments of edited review to the same lines
+// in the actual review, which can be shifted if pull request was rescoped.
+// Comments on lines, which are not found anymore, become file comments.
+func (r *Review) RebaseAnchors(edited *Review) {
+	edited.changeset.ForEachLine(
+		func(
+			diff *godiff.Diff, _ *godiff.Hunk,
+			segment *godiff.Segment, line *godiff.Line,
+		) error {
+			for _, comment := range line.Comments {
+				if comment.Id != 0 {
+					continue
+				}
+
+				r.rebaseAnchor(diff.Destination.ToString, line.Line, comment)
+			}
+
+			return nil
+		})
+}
+
+func (r *Review) rebaseAnchor(
+	path string, text string, comment *godiff.Comment,
+) {
+	found := false
+	distance := int64(0)
+	originalLine := comment.Anchor.Line
+
+	r.changeset.ForEachLine(
+		func(
+			diff *godiff.Diff, _ *godiff.Hunk,
+			segment *godiff.Segment, line *godiff.Line,
+		) error {
+			if diff.Destination.ToString != path || line.Line != text {
+				return nil
+			}
+
+			if segment.Type != comment.Anchor.LineType {
+				return nil
+			}
+
+			lineNum := segment.GetLineNum(line)
+
+			lineDistance := lineNum - originalLine
+			if lineDistance < 0 {
+				lineDistance = -lineDistance
+			}
+
+			if found && lineDistance >= distance {
+				return nil
+			}
+
+			found = true
+			distance = lineDistance
+
+			comment.Anchor.Line = lineNum
+			if len(diff.Attributes.FromHash) > 0 {
+				comment.Anchor.FromHash = diff.Attributes.FromHash[0]
+			}
+
+			if len(diff.Attributes.ToHash) > 0 {
+				comment.Anchor.ToHash = diff.Attributes.ToHash[0]
+			}
+
+			return nil
+		})
+
+	if !found {
+		logger.Warning(
+			"line %d of %s is not found anymore, "+
+				"comment will be added to the file",
+			originalLine, path,
+		)
+
+		comment.Anchor.Line = 0
+	}
+}
+
+func readReviewFile(path string) (*Review, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadReview(file)
+}
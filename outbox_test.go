@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutboxKeepsWrapWidth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ash-outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	defer func(path string) { cachePath = path }(cachePath)
+	cachePath = dir
+
+	reviewPath := filepath.Join(dir, "review.diff")
+	err = ioutil.WriteFile(reviewPath, []byte{}, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := PullRequest{
+		Repo: &Repo{
+			Project: &Project{&Api{URL: "http://stash.local"}, "projects/p"},
+			Name:    "repo",
+		},
+		Id: 1,
+	}
+
+	err = QueueToOutbox(&pr, "", reviewPath, reviewPath, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ListOutbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].WrapWidth != 72 {
+		t.Fatalf("wrap width is not kept in outbox: %#v", entries)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/bndr/gopencils"
 	"github.com/seletskiy/godiff"
@@ -15,6 +17,17 @@ func (u unexpectedStatusCode) Error() string {
 	return fmt.Sprintf("unexpected status code from Stash: %d", u)
 }
 
+type commentConflict struct {
+	remote *godiff.Comment
+}
+
+func (c commentConflict) Error() string {
+	return fmt.Sprintf(
+		"comment <%d> was changed by someone else (version %d)",
+		c.remote.Id, c.remote.Version,
+	)
+}
+
 type stashApiError []byte
 
 func (s stashApiError) Error() string {
@@ -26,8 +39,10 @@ type PullRequest struct {
 	Resource *gopencils.Resource
 
 	Id          int64
+	Title       string
 	Description string
 	State       string
+	CreatedDate UnixTimestamp
 	UpdatedDate UnixTimestamp
 	ReviewFiles ReviewFiles
 
@@ -65,18 +80,77 @@ type PullRequest struct {
 		}
 	}
 
+	Participants []struct {
+		User struct {
+			Name string
+		}
+	}
+
 	Properties struct {
 		CommentCount int64
 	}
+
+	Links struct {
+		Self []Link
+	}
+}
+
+type changesetWithReactions struct {
+	godiff.Changeset
+	Reactions CommentReactions
+}
+
+func (c *changesetWithReactions) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, &c.Changeset)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &c.Reactions)
 }
 
 type PullRequestInfo struct {
-	Version int64
-	Links   struct {
+	Version     int64
+	Title       string
+	Description string
+	State       string
+	Links       struct {
 		Self []struct {
 			Href string
 		}
 	}
+
+	FromRef struct {
+		Id        string
+		DisplayId string
+	}
+
+	ToRef struct {
+		Id        string
+		DisplayId string
+	}
+
+	Author struct {
+		User struct {
+			Name        string
+			DisplayName string
+		}
+	}
+
+	Reviewers []struct {
+		Approved bool
+		User     struct {
+			Name        string
+			DisplayName string
+		}
+	}
+}
+
+func (pr *PullRequest) URL() string {
+	return fmt.Sprintf(
+		"%s/%s/repos/%s/pull-requests/%d",
+		pr.Project.URL, pr.Project.Name, pr.Repo.Name, pr.Id,
+	)
 }
 
 func (pr *PullRequest) GetInfo() (*PullRequestInfo, error) {
@@ -89,30 +163,70 @@ func (pr *PullRequest) GetInfo() (*PullRequestInfo, error) {
 	return pr.Resource.Response.(*PullRequestInfo), nil
 }
 
+func (info *PullRequestInfo) GetReviewerNames() []string {
+	names := []string{}
+	for _, reviewer := range info.Reviewers {
+		names = append(names, reviewer.User.Name)
+	}
+
+	return names
+}
+
 func (pr *PullRequest) GetReview(
-	path string, ignoreWhitespaces bool,
+	path string, ignoreWhitespaces bool, contextLines string,
 ) (*Review, error) {
-	result := godiff.Changeset{}
+	response := changesetWithReactions{}
 
 	queryString := make(map[string]string)
 	if ignoreWhitespaces {
 		queryString["whitespace"] = "ignore-all"
 	}
 
+	if contextLines != "" {
+		queryString["contextLines"] = contextLines
+	}
+
 	err := pr.DoGet(
-		pr.Resource.Res("diff").Id(path, &result).SetQuery(queryString),
+		pr.Resource.Res("diff").Id(path, &response).SetQuery(queryString),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, diff := range result.Diffs {
-		diff.Attributes.FromHash = []string{result.FromHash}
-		diff.Attributes.ToHash = []string{result.ToHash}
+	result := response.Changeset
+
+	outdated := attachLineComments(&result)
+
+	result.Path = path
+
+	logger.Debug("successfully got review from Stash")
+
+	review := &Review{
+		changeset:  result,
+		isOverview: false,
+		reactions:  response.Reactions,
+	}
+
+	if len(outdated) > 0 {
+		logger.Debug("%d outdated comments found", len(outdated))
+		review.AddOutdatedComments(outdated)
+	}
+
+	return review, nil
+}
+
+// attachLineComments attaches line comments of every diff to the lines they
+// are left on. Comments left on lines, which are not present in the diff
+// anymore, are returned as outdated.
+func attachLineComments(changeset *godiff.Changeset) godiff.CommentsTree {
+	for _, diff := range changeset.Diffs {
+		diff.Attributes.FromHash = []string{changeset.FromHash}
+		diff.Attributes.ToHash = []string{changeset.ToHash}
 	}
 
-	// TODO: refactor block
-	result.ForEachLine(
+	attached := map[int64]bool{}
+
+	changeset.ForEachLine(
 		func(
 			diff *godiff.Diff, _ *godiff.Hunk,
 			_ *godiff.Segment, line *godiff.Line,
@@ -122,6 +236,7 @@ func (pr *PullRequest) GetReview(
 					if c.Id == id {
 						line.Comments = append(line.Comments, c)
 						diff.LineComments = append(diff.LineComments, c)
+						attached[c.Id] = true
 						break
 					}
 				}
@@ -130,14 +245,17 @@ func (pr *PullRequest) GetReview(
 			return nil
 		})
 
-	result.Path = path
-
-	logger.Debug("successfully got review from Stash")
+	outdated := godiff.CommentsTree{}
+	for _, diff := range changeset.Diffs {
+		for _, c := range diff.LineComments {
+			if !attached[c.Id] {
+				attached[c.Id] = true
+				outdated = append(outdated, c)
+			}
+		}
+	}
 
-	return &Review{
-		changeset:  result,
-		isOverview: false,
-	}, nil
+	return outdated
 }
 
 func (pr *PullRequest) Approve() error {
@@ -175,7 +293,26 @@ func (pr *PullRequest) Merge() error {
 	return pr.DoPost(pr.Resource.Res("merge", &resource).SetQuery(query))
 }
 
-func (pr *PullRequest) GetActivities(limit string) (*Review, error) {
+// GetActivities returns overview, where rescoped activities are described
+// with changed files of added commits and, optionally, their diffs.
+func (pr *PullRequest) GetActivities(
+	limit string, filter ActivityFilter, inlineCommits bool,
+) (*Review, error) {
+	review, activity, err := pr.GetFilteredActivities(limit, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	review.changeset.Diffs = pr.describeRescopes(activity, inlineCommits)
+
+	return review, nil
+}
+
+// GetFilteredActivities returns review with activities matched by filter
+// along with the activity itself, which holds newest activity id.
+func (pr *PullRequest) GetFilteredActivities(
+	limit string, filter ActivityFilter,
+) (*Review, *ReviewActivity, error) {
 	query := map[string]string{
 		"limit": limit,
 	}
@@ -184,9 +321,11 @@ func (pr *PullRequest) GetActivities(limit string) (*Review, error) {
 		Value ReviewActivity `json:"values"`
 	}{}
 
+	response.Value.Filter = filter
+
 	err := pr.DoGet(pr.Resource.Res("activities", &response), query)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	logger.Debug("successfully got review from Stash")
@@ -196,7 +335,8 @@ func (pr *PullRequest) GetActivities(limit string) (*Review, error) {
 			Diffs: response.Value.Changeset.Diffs,
 		},
 		isOverview: true,
-	}, nil
+		reactions:  response.Value.Reactions,
+	}, &response.Value, nil
 }
 
 func (pr *PullRequest) GetFiles() (ReviewFiles, error) {
@@ -221,6 +361,30 @@ func (pr *PullRequest) GetFiles() (ReviewFiles, error) {
 	return pr.ReviewFiles, nil
 }
 
+// GetFilesStats returns files list with line counts and number of comments,
+// which are taken from the whole pull request diff.
+func (pr *PullRequest) GetFilesStats() (ReviewFiles, error) {
+	files, err := pr.GetFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	changeset := godiff.Changeset{}
+
+	query := map[string]string{
+		"contextLines": "0",
+	}
+
+	err = pr.DoGet(pr.Resource.Res("diff", &changeset), query)
+	if err != nil {
+		return nil, err
+	}
+
+	files.SetStats(&changeset)
+
+	return files, nil
+}
+
 func (pr *PullRequest) ApplyChange(change ReviewChange) error {
 	switch c := change.(type) {
 	case ReplyAdded:
@@ -248,6 +412,12 @@ func (pr *PullRequest) ApplyChange(change ReviewChange) error {
 		logger.Info("adding file level comment: <%s>",
 			c.comment.Short(commentPreviewLen))
 		return pr.addComment(c)
+	case ReactionAdded:
+		logger.Info("reacting :%s: to <%d>", c.emoticon, c.comment.Id)
+		return pr.addReaction(c)
+	case DescriptionModified:
+		logger.Info("modifying description: <%s>", c.description)
+		return pr.modifyDescription(c)
 	default:
 		logger.Warning("unexpected <change> argument: %#v", change)
 	}
@@ -274,14 +444,14 @@ func (pr *PullRequest) modifyComment(change CommentModified) error {
 	}
 	result := godiff.Comment{}
 
-	err := pr.DoPut(
-		pr.Resource.
-			Res("comments").
-			Id(fmt.Sprint(change.comment.Id), &result).
-			SetQuery(query),
-		change.GetPayload())
+	req := pr.Resource.
+		Res("comments").
+		Id(fmt.Sprint(change.comment.Id), &result).
+		SetQuery(query)
+
+	err := pr.DoPut(req, change.GetPayload())
 	if err != nil {
-		return err
+		return pr.checkCommentConflict(req, change.comment, err)
 	}
 
 	logger.Info("comment modified: <%d>, version %d", result.Id, result.Version)
@@ -289,6 +459,35 @@ func (pr *PullRequest) modifyComment(change CommentModified) error {
 	return nil
 }
 
+// modifyDescription updates description of pull request, merging changes
+// made remotely since review was written.
+func (pr *PullRequest) modifyDescription(change DescriptionModified) error {
+	info, err := pr.GetInfo()
+	if err != nil {
+		return err
+	}
+
+	description := change.description
+	if strings.TrimSpace(info.Description) != change.original {
+		merged, ok := MergeText(
+			change.original, change.description,
+			strings.TrimSpace(info.Description),
+		)
+		if !ok {
+			return fmt.Errorf(
+				"description was modified by somebody else meanwhile",
+			)
+		}
+
+		description = merged
+	}
+
+	// reviewers, which are not passed, are removed by Stash
+	return pr.Update(
+		info.Version, info.Title, description, info.GetReviewerNames(),
+	)
+}
+
 func (pr *PullRequest) removeComment(change CommentRemoved) error {
 	query := map[string]string{
 		"version": fmt.Sprint(change.comment.Version),
@@ -305,10 +504,94 @@ func (pr *PullRequest) removeComment(change CommentRemoved) error {
 
 	err := pr.DoDelete(req)
 	if err != nil && req.Raw.StatusCode != 204 {
-		return err
+		return pr.checkCommentConflict(req, change.comment, err)
 	}
 
 	logger.Info("comment wasted: <%d>", change.comment.Id)
 
 	return nil
 }
+
+func (pr *PullRequest) GetComment(id int64) (*godiff.Comment, error) {
+	result := godiff.Comment{}
+
+	err := pr.DoGet(pr.Resource.Res("comments").Id(fmt.Sprint(id), &result))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ResolveCommentAuthors fetches authors of modified and removed comments,
+// which are not known, e.g. because comments were read from review file.
+func (pr *PullRequest) ResolveCommentAuthors(changes []ReviewChange) error {
+	for _, change := range changes {
+		var comment *godiff.Comment
+
+		switch c := change.(type) {
+		case CommentModified:
+			comment = c.original
+		case CommentRemoved:
+			comment = c.comment
+		default:
+			continue
+		}
+
+		if comment.Author.Name != "" {
+			continue
+		}
+
+		logger.Debug("fetching author of comment <%d>", comment.Id)
+
+		remote, err := pr.GetComment(comment.Id)
+		if err != nil {
+			return err
+		}
+
+		comment.Author.Name = remote.Author.Name
+	}
+
+	return nil
+}
+
+func (pr *PullRequest) checkCommentConflict(
+	req *gopencils.Resource, comment *godiff.Comment, err error,
+) error {
+	if req.Raw == nil || req.Raw.StatusCode != 409 {
+		return err
+	}
+
+	logger.Debug("comment <%d> is outdated, fetching actual version",
+		comment.Id)
+
+	remote, fetchErr := pr.GetComment(comment.Id)
+	if fetchErr != nil {
+		logger.Warning("can not fetch comment <%d>: %s",
+			comment.Id, fetchErr.Error())
+		return err
+	}
+
+	return commentConflict{remote}
+}
+
+func (pr *PullRequest) addReaction(change ReactionAdded) error {
+	result := make(map[string]interface{})
+
+	req := pr.GetResource().
+		Res("comment-likes/latest").
+		Res(pr.Project.Name).
+		Res("repos").Res(pr.Repo.Name).
+		Res("pull-requests").Id(fmt.Sprint(pr.Id)).
+		Res("comments").Id(fmt.Sprint(change.comment.Id)).
+		Res("reactions").Id(change.emoticon, &result)
+
+	err := pr.DoPut(req)
+	if err != nil && req.Raw.StatusCode != 204 {
+		return err
+	}
+
+	logger.Info("reaction added: <%d> :%s:", change.comment.Id, change.emoticon)
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of items requested from Stash at once for paged resources.
+const pageSize = 100
+
+type Link struct {
+	Href string
+	Name string
+}
+
+type ProjectInfo struct {
+	Key         string
+	Name        string
+	Description string
+	Public      bool
+	Links       struct {
+		Self []Link
+	}
+}
+
+type RepoInfo struct {
+	Slug    string
+	Name    string
+	State   string
+	Public  bool
+	Project struct {
+		Key string
+	}
+	Links struct {
+		Self  []Link
+		Clone []Link
+	}
+}
+
+type pagedReply struct {
+	IsLastPage    bool
+	NextPageStart int
+}
+
+// ListProjects returns projects, which key or name contains filter string.
+// All pages are requested until limit (if non-zero) is reached.
+func (api Api) ListProjects(filter string, limit int) ([]ProjectInfo, error) {
+	projects := []ProjectInfo{}
+
+	start := 0
+	for {
+		reply := struct {
+			pagedReply
+			Values []ProjectInfo
+		}{}
+
+		err := api.DoGet(
+			api.GetResource().Res("api/1.0").Res("projects", &reply),
+			getPageQuery(start),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, project := range reply.Values {
+			if !matchFilter(filter, project.Key, project.Name) {
+				continue
+			}
+
+			projects = append(projects, project)
+			if limit > 0 && len(projects) >= limit {
+				return projects, nil
+			}
+		}
+
+		if reply.IsLastPage || len(reply.Values) == 0 {
+			return projects, nil
+		}
+
+		start = reply.NextPageStart
+	}
+}
+
+// ListRepos returns repositories of the project, which slug or name contains
+// filter string.
+func (project Project) ListRepos(filter string, limit int) ([]RepoInfo, error) {
+	repos := []RepoInfo{}
+
+	start := 0
+	for {
+		reply := struct {
+			pagedReply
+			Values []RepoInfo
+		}{}
+
+		err := project.DoGet(
+			project.GetResource().Res("api/1.0").Res(project.Name).
+				Res("repos", &reply),
+			getPageQuery(start),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range reply.Values {
+			if !matchFilter(filter, repo.Slug, repo.Name) {
+				continue
+			}
+
+			repos = append(repos, repo)
+			if limit > 0 && len(repos) >= limit {
+				return repos, nil
+			}
+		}
+
+		if reply.IsLastPage || len(reply.Values) == 0 {
+			return repos, nil
+		}
+
+		start = reply.NextPageStart
+	}
+}
+
+// GetCloneURL returns clone URL of specified kind (ssh or http).
+func (repo RepoInfo) GetCloneURL(kind string) string {
+	for _, link := range repo.Links.Clone {
+		if link.Name == kind {
+			return link.Href
+		}
+	}
+
+	return ""
+}
+
+func getPageQuery(start int) map[string]string {
+	return map[string]string{
+		"start": fmt.Sprint(start),
+		"limit": fmt.Sprint(pageSize),
+	}
+}
+
+func matchFilter(filter string, values ...string) bool {
+	filter = strings.ToLower(filter)
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), filter) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var reReactionToken = regexp.MustCompile(`^(\+1|-1|:[a-z0-9_+-]+:)$`)
+
+var reactionAliases = map[string]string{
+	"+1": "thumbsup",
+	"-1": "thumbsdown",
+}
+
+type Reaction struct {
+	Emoticon string
+	Count    int
+}
+
+type CommentReactions map[int64][]Reaction
+
+func (reactions *CommentReactions) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	*reactions = CommentReactions{}
+	reactions.collect(value)
+
+	return nil
+}
+
+// collect walks through arbitrary Stash response and picks up reactions
+// (or likes in case of older Stash versions) of every comment found.
+func (reactions CommentReactions) collect(value interface{}) {
+	switch node := value.(type) {
+	case []interface{}:
+		for _, item := range node {
+			reactions.collect(item)
+		}
+
+	case map[string]interface{}:
+		for _, item := range node {
+			reactions.collect(item)
+		}
+
+		id, ok := node["id"].(float64)
+		if !ok {
+			return
+		}
+
+		if _, ok := node["text"].(string); !ok {
+			return
+		}
+
+		properties, ok := node["properties"].(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		found := []Reaction{}
+
+		if likes, ok := properties["likedBy"].(map[string]interface{}); ok {
+			if total, ok := likes["total"].(float64); ok && total > 0 {
+				found = append(found, Reaction{"thumbsup", int(total)})
+			}
+		}
+
+		list, _ := properties["reactions"].([]interface{})
+		for _, item := range list {
+			reaction := struct {
+				Emoticon struct {
+					Shortcut string
+				}
+				Users []interface{}
+			}{}
+
+			raw, _ := json.Marshal(item)
+			if json.Unmarshal(raw, &reaction) != nil {
+				continue
+			}
+
+			found = append(found,
+				Reaction{reaction.Emoticon.Shortcut, len(reaction.Users)})
+		}
+
+		if len(found) > 0 {
+			reactions[int64(id)] = found
+		}
+	}
+}
+
+func (reactions CommentReactions) Summary(id int64) string {
+	summary := []string{}
+	for _, reaction := range reactions[id] {
+		summary = append(summary,
+			fmt.Sprintf(":%s: %d", reaction.Emoticon, reaction.Count))
+	}
+
+	return strings.Join(summary, ", ")
+}
+
+// ParseReactionToken returns emoticon name if given text consists only of
+// reaction token like '+1' or ':heart:'.
+func ParseReactionToken(text string) (string, bool) {
+	token := strings.TrimSpace(text)
+	if !reReactionToken.MatchString(token) {
+		return "", false
+	}
+
+	if emoticon, ok := reactionAliases[token]; ok {
+		return emoticon, true
+	}
+
+	return strings.Trim(token, ":"), true
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/seletskiy/godiff"
+)
+
+var reAshModeline = regexp.MustCompile(
+	`ash: review-url=(\S+) (overview|file=(.*?))\s*$`,
+)
+
+type AshModeline struct {
+	URL      string
+	File     string
+	Overview bool
+}
+
+func ParseAshModeline(r io.Reader) (*AshModeline, error) {
+	var modeline *AshModeline
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		matches := reAshModeline.FindStringSubmatch(scanner.Text())
+		if len(matches) == 0 {
+			continue
+		}
+
+		modeline = &AshModeline{
+			URL:      matches[1],
+			File:     matches[3],
+			Overview: matches[2] == "overview",
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if modeline == nil {
+		return nil, fmt.Errorf("ash modeline is not found")
+	}
+
+	return modeline, nil
+}
+
+// Refresh returns copy of the review with local changes (new, modified and
+// deleted comments) made in the specified review applied on top of it. Comments
+// missing in local review are considered deleted only if they were present in
+// it when it was written, otherwise they are new remote ones.
+func (r *Review) Refresh(local *Review) *Review {
+	refreshed := &Review{
+		changeset:  copyChangeset(r.changeset),
+		isOverview: r.isOverview,
+		wrapWidth:  r.wrapWidth,
+		reactions:  r.reactions,
+		outdated:   r.outdated,
+	}
+
+	if r.header != nil {
+		header := *r.header
+		if local.header != nil {
+			header.Description = local.header.Description
+
+			// local description is edited on top of the one it was
+			// written with, not the new remote one
+			refreshed.description = local.description
+			if refreshed.description == nil {
+				refreshed.description = &r.header.Description
+			}
+		}
+
+		refreshed.header = &header
+	}
+
+	localComments, isKnown := getKnownComments(local)
+
+	refreshed.filterComments(func(comment *godiff.Comment) bool {
+		_, ok := localComments[comment.Id]
+		return ok || !isKnown(comment.Id)
+	})
+
+	remoteComments := map[int64]*godiff.Comment{}
+	refreshed.changeset.ForEachComment(
+		func(_ *godiff.Diff, comment, _ *godiff.Comment) {
+			remoteComments[comment.Id] = comment
+
+			if localComment, ok := localComments[comment.Id]; ok {
+				comment.Text = localComment.Text
+			}
+		})
+
+	local.changeset.ForEachComment(
+		func(diff *godiff.Diff, comment, parent *godiff.Comment) {
+			if comment.Id != 0 {
+				return
+			}
+
+			switch {
+			case parent == nil:
+				refreshed.addLocalComment(diff, comment)
+
+			case parent.Id == 0:
+				// reply to new comment, will be added along with parent
+
+			case remoteComments[parent.Id] != nil:
+				remoteParent := remoteComments[parent.Id]
+				remoteParent.Comments = append(remoteParent.Comments, comment)
+
+			default:
+				logger.Warning(
+					"comment <%d> is deleted, reply is kept as file comment",
+					parent.Id,
+				)
+
+				refreshed.addLocalComment(diff, comment)
+			}
+		})
+
+	return refreshed
+}
+
+// ForgetNewerComments removes comments, which were posted after specified
+// review had been written, so they will not be considered deleted from it.
+func (r *Review) ForgetNewerComments(local *Review) {
+	localComments, isKnown := getKnownComments(local)
+
+	r.filterComments(func(comment *godiff.Comment) bool {
+		_, ok := localComments[comment.Id]
+		return ok || isKnown(comment.Id)
+	})
+}
+
+// getKnownComments returns comments of the review along with function,
+// which reports if comment was present in the review when it was written.
+// Files without list of known comments are written by older versions, so
+// only comments older than the newest one of the file are considered known.
+func getKnownComments(
+	review *Review,
+) (map[int64]*godiff.Comment, func(int64) bool) {
+	comments := map[int64]*godiff.Comment{}
+	lastSeenId := int64(0)
+
+	review.changeset.ForEachComment(
+		func(_ *godiff.Diff, comment, _ *godiff.Comment) {
+			if comment.Id == 0 {
+				return
+			}
+
+			comments[comment.Id] = comment
+			if comment.Id > lastSeenId {
+				lastSeenId = comment.Id
+			}
+		})
+
+	if review.known != nil {
+		return comments, func(id int64) bool {
+			return review.known[id]
+		}
+	}
+
+	logger.Debug("review file has no list of known comments")
+
+	return comments, func(id int64) bool {
+		return id <= lastSeenId
+	}
+}
+
+func (r *Review) filterComments(keep func(*godiff.Comment) bool) {
+	for _, diff := range r.changeset.Diffs {
+		diff.FileComments = filterComments(diff.FileComments, keep)
+	}
+
+	r.changeset.ForEachLine(
+		func(
+			_ *godiff.Diff, _ *godiff.Hunk,
+			_ *godiff.Segment, line *godiff.Line,
+		) error {
+			line.Comments = filterComments(line.Comments, keep)
+			return nil
+		})
+}
+
+func (r *Review) addLocalComment(localDiff *godiff.Diff, comment *godiff.Comment) {
+	path := localDiff.Destination.ToString
+
+	if comment.Anchor.Line != 0 {
+		placed := false
+
+		r.changeset.ForEachLine(
+			func(
+				diff *godiff.Diff, _ *godiff.Hunk,
+				segment *godiff.Segment, line *godiff.Line,
+			) error {
+				if placed || diff.Destination.ToString != path {
+					return nil
+				}
+
+				if segment.Type != comment.Anchor.LineType {
+					return nil
+				}
+
+				if segment.GetLineNum(line) != comment.Anchor.Line {
+					return nil
+				}
+
+				line.Comments = append(line.Comments, comment)
+				placed = true
+
+				return nil
+			})
+
+		if placed {
+			return
+		}
+
+		logger.Warning(
+			"line %d is not found in refreshed review, comment is kept "+
+				"as file comment", comment.Anchor.Line,
+		)
+	}
+
+	for _, diff := range r.changeset.Diffs {
+		if diff.Destination.ToString == path {
+			diff.FileComments = append(diff.FileComments, comment)
+			return
+		}
+	}
+
+	r.changeset.Diffs = append(
+		[]*godiff.Diff{
+			&godiff.Diff{
+				FileComments: godiff.CommentsTree{comment},
+			},
+		},
+		r.changeset.Diffs...,
+	)
+}
+
+func filterComments(
+	comments godiff.CommentsTree, keep func(*godiff.Comment) bool,
+) godiff.CommentsTree {
+	if comments == nil {
+		return nil
+	}
+
+	result := godiff.CommentsTree{}
+	for _, comment := range comments {
+		if !keep(comment) {
+			continue
+		}
+
+		comment.Comments = filterComments(comment.Comments, keep)
+		result = append(result, comment)
+	}
+
+	return result
+}
+
+func copyComments(comments godiff.CommentsTree) godiff.CommentsTree {
+	if comments == nil {
+		return nil
+	}
+
+	result := make(godiff.CommentsTree, len(comments))
+	for i, comment := range comments {
+		copied := *comment
+		copied.Comments = copyComments(comment.Comments)
+		result[i] = &copied
+	}
+
+	return result
+}
+
+func copyChangeset(changeset godiff.Changeset) godiff.Changeset {
+	result := changeset
+	result.Diffs = make([]*godiff.Diff, len(changeset.Diffs))
+
+	for i, diff := range changeset.Diffs {
+		copiedDiff := *diff
+		copiedDiff.FileComments = copyComments(diff.FileComments)
+		copiedDiff.Hunks = make([]*godiff.Hunk, len(diff.Hunks))
+
+		for j, hunk := range diff.Hunks {
+			copiedHunk := *hunk
+			copiedHunk.Segments = make([]*godiff.Segment, len(hunk.Segments))
+
+			for k, segment := range hunk.Segments {
+				copiedSegment := *segment
+				copiedSegment.Lines = make([]*godiff.Line, len(segment.Lines))
+
+				for l, line := range segment.Lines {
+					copiedLine := *line
+					copiedLine.Comments = copyComments(line.Comments)
+					copiedSegment.Lines[l] = &copiedLine
+				}
+
+				copiedHunk.Segments[k] = &copiedSegment
+			}
+
+			copiedDiff.Hunks[j] = &copiedHunk
+		}
+
+		result.Diffs[i] = &copiedDiff
+	}
+
+	return result
+}
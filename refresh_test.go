@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/seletskiy/godiff"
+)
+
+func TestParseAshModeline(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected *AshModeline
+	}{
+		{
+			"### some note\n" +
+				"### ash: review-url=http://stash/projects/p/repos/r/pull-requests/1 overview\n",
+			&AshModeline{
+				URL:      "http://stash/projects/p/repos/r/pull-requests/1",
+				Overview: true,
+			},
+		},
+		{
+			" 1\n+2\n" +
+				"### ash: review-url=http://stash/users/u/repos/r/pull-requests/2 file=a/b.go \n",
+			&AshModeline{
+				URL:  "http://stash/users/u/repos/r/pull-requests/2",
+				File: "a/b.go",
+			},
+		},
+		{
+			" 1\n+2\n",
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseAshModeline(strings.NewReader(test.text))
+		if test.expected == nil {
+			if err == nil {
+				t.Fatalf("error expected for text without modeline")
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected modeline\n%#v\n%#v", test.expected, actual)
+		}
+	}
+}
+
+func TestForgetNewerComments(t *testing.T) {
+	remote := makeReviewWithComments(1, 2, 3, 4)
+
+	// comment 3 is the newest one in the file and it is deleted there,
+	// comment 4 is posted after file was written
+	local := makeReviewWithComments(1)
+	local.known = map[int64]bool{1: true, 2: true, 3: true}
+
+	remote.ForgetNewerComments(local)
+
+	expected := []int{1, 2, 3}
+	if actual := getCommentIds(remote); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected comments\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestRefreshKeepsNewRemoteComments(t *testing.T) {
+	remote := makeReviewWithComments(1, 2, 3)
+
+	local := makeReviewWithComments()
+	local.known = map[int64]bool{1: true, 2: true}
+
+	expected := []int{3}
+	actual := getCommentIds(remote.Refresh(local))
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected comments\n%#v\n%#v", expected, actual)
+	}
+}
+
+func makeReviewWithComments(ids ...int64) *Review {
+	diff := &godiff.Diff{}
+	for _, id := range ids {
+		diff.FileComments = append(diff.FileComments, &godiff.Comment{Id: id})
+	}
+
+	review := &Review{}
+	review.changeset.Diffs = []*godiff.Diff{diff}
+
+	return review
+}
+
+func getCommentIds(review *Review) []int {
+	ids := []int{}
+	review.changeset.ForEachComment(
+		func(_ *godiff.Diff, comment, _ *godiff.Comment) {
+			ids = append(ids, int(comment.Id))
+		})
+
+	sort.Ints(ids)
+
+	return ids
+}
+
+func TestRefreshKeepsOriginalDescription(t *testing.T) {
+	original := "written"
+
+	remote := &Review{header: &ReviewHeader{Description: "changed remotely"}}
+	local := &Review{
+		header:      &ReviewHeader{Description: "edited"},
+		description: &original,
+	}
+
+	refreshed := remote.Refresh(local)
+
+	if refreshed.header.Description != "edited" ||
+		*refreshed.getOriginalDescription() != "written" {
+		t.Fatalf("unexpected description %q, original %q",
+			refreshed.header.Description,
+			*refreshed.getOriginalDescription())
+	}
+}
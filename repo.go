@@ -30,6 +30,7 @@ func (repo *Repo) ListPullRequest(state string) ([]PullRequest, error) {
 
 	query := map[string]string{
 		"state": state,
+		"limit": "1000",
 	}
 
 	err := repo.DoGet(repo.Resource.Res("pull-requests", &reply), query)
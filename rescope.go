@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/seletskiy/godiff"
+)
+
+// commitRequests is number of simultaneous requests of commit diffs.
+const commitRequests = 8
+
+// GetCommitDiff returns diff of the commit; contextLines can be set to "0"
+// if only changed lines are needed, e.g. to count them.
+func (pr *PullRequest) GetCommitDiff(
+	id string, contextLines string,
+) (*godiff.Changeset, error) {
+	changeset := godiff.Changeset{}
+
+	query := map[string]string{}
+	if contextLines != "" {
+		query["contextLines"] = contextLines
+	}
+
+	err := pr.DoGet(
+		pr.Repo.Resource.Res("commits").Res(id).Res("diff", &changeset),
+		query,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &changeset, nil
+}
+
+// describeRescopes populates added commits of rescoped activities with
+// changed files and line counts, which are taken from diff of every commit.
+// If inlineDiffs is set, returned diffs also contain diff of every added
+// commit right after the rescoped note.
+func (pr *PullRequest) describeRescopes(
+	activity *ReviewActivity, inlineDiffs bool,
+) []*godiff.Diff {
+	contextLines := "0"
+	if inlineDiffs {
+		contextLines = ""
+	}
+
+	commits := []*rescopedChangeset{}
+	for _, rescoped := range activity.rescopes {
+		for i := range rescoped.value.Added.Commits {
+			commits = append(commits, &rescoped.value.Added.Commits[i])
+		}
+	}
+
+	changesets := pr.getCommitDiffs(commits, contextLines)
+
+	commitDiffs := map[*godiff.Diff][]*godiff.Diff{}
+
+	index := 0
+	for _, rescoped := range activity.rescopes {
+		for range rescoped.value.Added.Commits {
+			commit, changeset := commits[index], changesets[index]
+			index++
+
+			if changeset == nil {
+				continue
+			}
+
+			commit.Files = getCommitFiles(changeset)
+
+			if !inlineDiffs {
+				continue
+			}
+
+			diff, err := getCommitDiffNote(commit, changeset)
+			if err != nil {
+				logger.Warning(
+					"can not render diff of commit %s: %s",
+					commit.DisplayId, err.Error(),
+				)
+				continue
+			}
+
+			commitDiffs[rescoped.diff] = append(
+				commitDiffs[rescoped.diff], diff,
+			)
+		}
+
+		err := rescoped.render()
+		if err != nil {
+			logger.Warning("can not render rescoped activity: %s", err.Error())
+		}
+	}
+
+	if !inlineDiffs {
+		return activity.Diffs
+	}
+
+	diffs := []*godiff.Diff{}
+	for _, diff := range activity.Diffs {
+		diffs = append(diffs, diff)
+		diffs = append(diffs, commitDiffs[diff]...)
+	}
+
+	return diffs
+}
+
+// getCommitDiffs requests diffs of commits simultaneously; diff of commit,
+// which can not be requested, is nil.
+func (pr *PullRequest) getCommitDiffs(
+	commits []*rescopedChangeset, contextLines string,
+) []*godiff.Changeset {
+	changesets := make([]*godiff.Changeset, len(commits))
+	requests := make(chan struct{}, commitRequests)
+
+	group := sync.WaitGroup{}
+	for i, commit := range commits {
+		group.Add(1)
+
+		go func(i int, commit *rescopedChangeset) {
+			defer group.Done()
+
+			requests <- struct{}{}
+			defer func() { <-requests }()
+
+			changeset, err := pr.GetCommitDiff(commit.Id, contextLines)
+			if err != nil {
+				logger.Warning(
+					"can not get diff of commit %s: %s",
+					commit.DisplayId, err.Error(),
+				)
+				return
+			}
+
+			changesets[i] = changeset
+		}(i, commit)
+	}
+
+	group.Wait()
+
+	return changesets
+}
+
+// getCommitFiles returns files changed in commit diff with line counts.
+func getCommitFiles(changeset *godiff.Changeset) ReviewFiles {
+	files := ReviewFiles{}
+
+	for _, diff := range changeset.Diffs {
+		file := ReviewFile{
+			ChangeType: "MODIFY",
+			SrcPath:    diff.Source.ToString,
+			DstPath:    diff.Destination.ToString,
+		}
+
+		switch {
+		case file.SrcPath == "":
+			file.ChangeType = "ADD"
+		case file.DstPath == "":
+			file.ChangeType = "DELETE"
+			file.DstPath = file.SrcPath
+		case file.SrcPath != file.DstPath:
+			file.ChangeType = "MOVE"
+		}
+
+		file.Parent, file.Name = path.Split(file.DstPath)
+		file.Parent = strings.TrimSuffix(file.Parent, "/")
+
+		files = append(files, file)
+	}
+
+	files.SetStats(changeset)
+
+	return files
+}
+
+// getCommitDiffNote returns diff of commit written as note, so it is
+// read-only: lines of commit can not be commented in pull request.
+func getCommitDiffNote(
+	commit *rescopedChangeset, changeset *godiff.Changeset,
+) (*godiff.Diff, error) {
+	buffer := &bytes.Buffer{}
+
+	err := godiff.WriteChangeset(
+		godiff.Changeset{Diffs: changeset.Diffs}, buffer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &godiff.Diff{
+		Note: "Diff of commit " + commit.DisplayId + ":\n\n" +
+			strings.TrimRight(buffer.String(), "\n"),
+	}, nil
+}
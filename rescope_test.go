@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seletskiy/godiff"
+)
+
+func TestRescopedRenderCommitFiles(t *testing.T) {
+	rescoped := &reviewActionRescoped{}
+	err := json.Unmarshal([]byte(`{
+		"action": "RESCOPED",
+		"added": {"commits": [{
+			"id": "abcdef", "displayId": "abc", "message": "Fix build",
+			"author": {"displayName": "Alice"}
+		}]}
+	}`), rescoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rescoped.value.Added.Commits[0].Files = ReviewFiles{
+		{
+			Name: "main.go", DstPath: "cmd/main.go", ChangeType: "MODIFY",
+			Added: 2, Removed: 1,
+		},
+		{Name: "main.go", DstPath: "main.go", ChangeType: "ADD", Added: 1},
+	}
+
+	err = rescoped.render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "+ abc | Alice | "
+	if !strings.Contains(rescoped.diff.Note, expected) {
+		t.Fatalf("commit is not rendered:\n%s", rescoped.diff.Note)
+	}
+
+	expected = "---\nFix build\n---\nM cmd/main.go (+2 -1)\nA main.go (+1 -0)"
+	if !strings.HasSuffix(rescoped.diff.Note, expected) {
+		t.Fatalf("files are not rendered:\n%s", rescoped.diff.Note)
+	}
+}
+
+func TestGetCommitFiles(t *testing.T) {
+	changeset := godiff.Changeset{}
+	err := json.Unmarshal([]byte(`{
+		"diffs": [
+			{
+				"source": {"toString": "cmd/main.go"},
+				"destination": {"toString": "cmd/main.go"},
+				"hunks": [{"segments": [
+					{"type": "REMOVED", "lines": [{"line": "b"}]},
+					{"type": "ADDED", "lines": [{"line": "c"}, {"line": "d"}]}
+				]}]
+			},
+			{
+				"destination": {"toString": "new.go"},
+				"hunks": [{"segments": [
+					{"type": "ADDED", "lines": [{"line": "e"}]}
+				]}]
+			},
+			{
+				"source": {"toString": "old.go"},
+				"destination": {"toString": "lib/renamed.go"}
+			},
+			{
+				"source": {"toString": "lib/removed.go"},
+				"hunks": [{"segments": [
+					{"type": "REMOVED", "lines": [{"line": "f"}]}
+				]}]
+			}
+		]
+	}`), &changeset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"M cmd/main.go (+2 -1)",
+		"A new.go (+1 -0)",
+		"R old.go -> lib/renamed.go",
+		"D lib/removed.go (+0 -1)",
+	}
+
+	actual := []string{}
+	for _, file := range getCommitFiles(&changeset) {
+		actual = append(actual, file.WithPath().String())
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected files\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestCommitDiffIsReadOnly(t *testing.T) {
+	commit := &rescopedChangeset{DisplayId: "abc"}
+
+	diff, err := getCommitDiffNote(commit, &godiff.Changeset{
+		Diffs: []*godiff.Diff{{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(diff.Note, "Diff of commit abc:") {
+		t.Fatalf("unexpected note of commit diff: %q", diff.Note)
+	}
+
+	if len(diff.Hunks) != 0 {
+		t.Fatalf("commit diff should have no lines to comment")
+	}
+}
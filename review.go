@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/seletskiy/godiff"
@@ -16,16 +18,46 @@ const usageText = "Oh, hello there!\n\n" +
 	"* You can add line comments after specific lines.\n" +
 	"* You can add file comments outside of the diff.\n" +
 	"* You can add review comments outside of the diff (in the overview mode).\n" +
+	"* Reply with +1, -1 or :emoticon: to react on the comment.\n" +
+	"* Wrap replacement code for the commented line into ~~~suggestion and ~~~\n" +
+	"  lines to suggest a change.\n" +
+	"* Edit text between description markers in the overview to change\n" +
+	"  description of the pull request.\n" +
 	"* If you want to delete comment, you need to remove all it's contents\n" +
 	"  including header."
 
+const outdatedCommentsNote = "Outdated comments\n" +
+	"===\n\n" +
+	"Following comments were left on lines which are not present in the diff\n" +
+	"anymore. Replies and modifications are applied as usual, but outdated\n" +
+	"comments are not deleted when missing from the file. To delete one,\n" +
+	"remove its text leaving the header in place. New comments added here\n" +
+	"are left on the reviewed file."
+
 const vimModeline = "vim: ft=diff"
 
 var reDanglingSpace = regexp.MustCompile(`(?m)\s*$`)
 
+var reOutdatedMarker = regexp.MustCompile(`ash: outdated-comments=([\d,]*)`)
+
+var reKnownMarker = regexp.MustCompile(`ash: known-comments=([\d,]*)`)
+
 type Review struct {
 	changeset  godiff.Changeset
 	isOverview bool
+	wrapWidth  int
+	reactions  CommentReactions
+	outdated   map[int64]bool
+	header     *ReviewHeader
+
+	// known holds ids of comments, which were present in the review when it
+	// was written to file; nil for reviews written by older versions.
+	known map[int64]bool
+
+	// description is description of pull request as it was when review was
+	// written to file, so only changes made in file are sent; nil for
+	// reviews written by older versions.
+	description *string
 }
 
 type ReviewChange interface {
@@ -80,7 +112,12 @@ func (added ReplyAdded) String() string {
 }
 
 type CommentModified struct {
-	comment *godiff.Comment
+	comment  *godiff.Comment
+	original *godiff.Comment
+
+	// wrapWidth is used to bring original and remote texts to the same form
+	// as modified one when resolving conflicts.
+	wrapWidth int
 }
 
 func (added CommentModified) String() string {
@@ -101,6 +138,46 @@ func (added CommentRemoved) String() string {
 	)
 }
 
+func (removed CommentRemoved) Details() string {
+	return fmt.Sprintf(
+		"[%d] %s at %s:\n%s",
+		removed.comment.Id,
+		removed.comment.Author.Name,
+		commentLocation(removed.comment),
+		indent(removed.comment.Text, " > "),
+	)
+}
+
+type IllegalChange struct {
+	comment *godiff.Comment
+	author  string
+	action  string
+}
+
+func (illegal IllegalChange) Error() string {
+	return fmt.Sprintf(
+		"Comment [%d] at %s is authored by %s and can not be %s:\n%s",
+		illegal.comment.Id,
+		commentLocation(illegal.comment),
+		illegal.author,
+		illegal.action,
+		indent(illegal.comment.Text, " > "),
+	)
+}
+
+type ReactionAdded struct {
+	comment  *godiff.Comment
+	emoticon string
+}
+
+func (added ReactionAdded) String() string {
+	return fmt.Sprintf(
+		"Reaction :%s: added to:\n%s",
+		added.emoticon,
+		indent(added.comment.Text, " | "),
+	)
+}
+
 func (c LineCommentAdded) GetPayload() map[string]interface{} {
 	return map[string]interface{}{
 		"text": c.comment.Text,
@@ -168,8 +245,20 @@ func (c CommentRemoved) GetPayload() map[string]interface{} {
 	}
 }
 
+func (c ReactionAdded) GetPayload() map[string]interface{} {
+	return map[string]interface{}{
+		"id":       c.comment.Id,
+		"emoticon": c.emoticon,
+	}
+}
+
 func ReadReview(r io.Reader) (*Review, error) {
-	changeset, err := godiff.ReadChangeset(r)
+	rest, header, err := readReviewHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	changeset, err := godiff.ReadChangeset(strings.NewReader(rest))
 	if err != nil {
 		return nil, err
 	}
@@ -177,6 +266,11 @@ func ReadReview(r io.Reader) (*Review, error) {
 	return &Review{
 		changeset:  changeset,
 		isOverview: false,
+		header:     header,
+		outdated:   readCommentIds(rest, reOutdatedMarker),
+		known:      readCommentIds(rest, reKnownMarker),
+
+		description: readOriginalDescription(rest),
 	}, nil
 }
 
@@ -205,23 +299,70 @@ func AddUsageComment(r *Review) {
 func AddAshModeline(url string, review *Review) {
 	fileTag := "overview"
 	if !review.isOverview {
-		fileName := review.changeset.Diffs[0].Source.ToString
-		if fileName == "" {
-			fileName = review.changeset.Diffs[0].Destination.ToString
+		fileName := ""
+		if diff := getReviewedFileDiff(review.changeset); diff != nil {
+			fileName = diff.Source.ToString
+			if fileName == "" {
+				fileName = diff.Destination.ToString
+			}
 		}
 
 		fileTag = fmt.Sprintf("file=%s", fileName)
 	}
 
+	known := map[int64]bool{}
+	review.changeset.ForEachComment(
+		func(_ *godiff.Diff, comment, _ *godiff.Comment) {
+			if comment.Id != 0 {
+				known[comment.Id] = true
+			}
+		})
+
+	note := fmt.Sprintf(
+		"ash: review-url=%s %s\nash: known-comments=%s",
+		url, fileTag, formatCommentIds(known),
+	)
+
+	if description := review.getOriginalDescription(); description != nil {
+		note += "\n" + formatOriginalDescription(*description)
+	}
+
 	review.changeset.Diffs = append(
 		review.changeset.Diffs,
 		&godiff.Diff{
-			Note: fmt.Sprintf("ash: review-url=%s %s", url, fileTag),
+			Note: note,
 		},
 	)
 }
 
 func WriteReview(review *Review, writer io.Writer) error {
+	originalComments := map[*godiff.Comment]godiff.Comment{}
+
+	review.changeset.ForEachComment(
+		func(_ *godiff.Diff, comment, _ *godiff.Comment) {
+			originalComments[comment] = *comment
+
+			comment.Text = WrapText(comment.Text, review.wrapWidth)
+
+			summary := review.reactions.Summary(comment.Id)
+			if summary != "" {
+				comment.Author.DisplayName += " | " + summary
+			}
+		})
+
+	defer func() {
+		for comment, original := range originalComments {
+			*comment = original
+		}
+	}()
+
+	if review.header != nil {
+		err := review.header.Write(writer)
+		if err != nil {
+			return err
+		}
+	}
+
 	return godiff.WriteChangeset(review.changeset, writer)
 }
 
@@ -237,28 +378,143 @@ func (current *Review) Compare(another *Review) []ReviewChange {
 
 	another.changeset.ForEachComment(
 		func(diff *godiff.Diff, comment, parent *godiff.Comment) {
-			change := matchCommentChange(existComments, comment, parent)
+			comment.Text = normalizeCommentText(comment.Text, current.wrapWidth)
+
+			change := matchCommentChange(
+				existComments, comment, parent, current.wrapWidth,
+			)
 			if _, ok := change.(ReviewCommentAdded); ok && !current.isOverview {
-				comment.Anchor.Path = diff.Destination.ToString
-				comment.Anchor.SrcPath = diff.Source.ToString
+				// comments outside of the file diff, like ones in outdated
+				// comments section, are added to the reviewed file
+				fileDiff := diff
+				if fileDiff.Source.ToString == "" &&
+					fileDiff.Destination.ToString == "" {
+					fileDiff = getReviewedFileDiff(another.changeset)
+				}
+
+				if fileDiff != nil {
+					comment.Anchor.Path = fileDiff.Destination.ToString
+					comment.Anchor.SrcPath = fileDiff.Source.ToString
+				}
+
 				change = FileCommentAdded{comment}
 			}
 
+			if modified, ok := change.(CommentModified); ok {
+				if current.isOutdatedComment(modified.comment) &&
+					trimCommentSpaces(modified.comment.Text) == "" {
+					change = CommentRemoved{modified.original}
+				}
+			}
+
 			if change != nil {
 				changes = append(changes, change)
 			}
 		})
 
+	for i, comment := range existComments {
+		if comment != nil && current.isOutdatedComment(comment) {
+			existComments[i] = nil
+		}
+	}
+
 	changes = markRemovedComments(existComments, changes)
 
-	return changes
+	return append(compareDescriptions(current, another), changes...)
+}
+
+func (r *Review) AddOutdatedComments(comments godiff.CommentsTree) {
+	if len(comments) == 0 {
+		return
+	}
+
+	if r.outdated == nil {
+		r.outdated = map[int64]bool{}
+	}
+
+	markOutdatedComments(comments, r.outdated)
+
+	// ids are kept in the file, so review read back from it, like origin or
+	// cached one, still knows which comments are outdated
+	note := outdatedCommentsNote + "\n\nash: outdated-comments=" +
+		formatCommentIds(r.outdated)
+
+	r.changeset.Diffs = append(r.changeset.Diffs, &godiff.Diff{
+		Note:         note,
+		FileComments: comments,
+	})
+}
+
+func formatCommentIds(ids map[int64]bool) string {
+	sorted := []int{}
+	for id := range ids {
+		sorted = append(sorted, int(id))
+	}
+
+	sort.Ints(sorted)
+
+	result := []string{}
+	for _, id := range sorted {
+		result = append(result, strconv.Itoa(id))
+	}
+
+	return strings.Join(result, ",")
+}
+
+// readCommentIds reads list of comment ids written after marker, returning
+// nil if there is no marker in the text.
+func readCommentIds(text string, marker *regexp.Regexp) map[int64]bool {
+	matches := marker.FindStringSubmatch(text)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	ids := map[int64]bool{}
+	for _, id := range strings.Split(matches[1], ",") {
+		value, err := strconv.ParseInt(id, 10, 64)
+		if err == nil {
+			ids[value] = true
+		}
+	}
+
+	return ids
+}
+
+// getReviewedFileDiff returns first diff, which has file name, skipping notes
+// like files list.
+func getReviewedFileDiff(changeset godiff.Changeset) *godiff.Diff {
+	for _, diff := range changeset.Diffs {
+		if diff.Source.ToString != "" || diff.Destination.ToString != "" {
+			return diff
+		}
+	}
+
+	return nil
+}
+
+func markOutdatedComments(
+	comments godiff.CommentsTree, outdated map[int64]bool,
+) {
+	for _, comment := range comments {
+		outdated[comment.Id] = true
+		markOutdatedComments(comment.Comments, outdated)
+	}
+}
+
+func (r *Review) isOutdatedComment(comment *godiff.Comment) bool {
+	return r.outdated[comment.Id]
 }
 
 func matchCommentChange(
 	comments []*godiff.Comment, comment, parent *godiff.Comment,
+	wrapWidth int,
 ) ReviewChange {
 	if comment.Id == 0 {
 		if parent != nil {
+			if emoticon, ok := ParseReactionToken(comment.Text); ok {
+				return ReactionAdded{parent, emoticon}
+			}
+
 			return ReplyAdded{comment, parent}
 		} else {
 			if comment.Anchor.Line == 0 {
@@ -273,8 +529,11 @@ func matchCommentChange(
 				continue
 			}
 			comments[i] = nil
-			if trimCommentSpaces(c.Text) != trimCommentSpaces(comment.Text) {
-				return CommentModified{comment}
+
+			existText := normalizeCommentText(c.Text, wrapWidth)
+
+			if trimCommentSpaces(existText) != trimCommentSpaces(comment.Text) {
+				return CommentModified{comment, c, wrapWidth}
 			}
 		}
 	}
@@ -294,6 +553,50 @@ func markRemovedComments(
 	return changes
 }
 
+// ValidateChanges reports modifications and removals of comments, which are
+// not authored by specified user, so Stash will reject them anyway.
+func ValidateChanges(changes []ReviewChange, user string) []error {
+	illegal := []error{}
+
+	for _, change := range changes {
+		switch c := change.(type) {
+		case CommentModified:
+			if !strings.EqualFold(c.original.Author.Name, user) {
+				illegal = append(illegal, IllegalChange{
+					c.comment, c.original.Author.Name, "modified",
+				})
+			}
+		case CommentRemoved:
+			if !strings.EqualFold(c.comment.Author.Name, user) {
+				illegal = append(illegal, IllegalChange{
+					c.comment, c.comment.Author.Name, "deleted",
+				})
+			}
+		}
+	}
+
+	return illegal
+}
+
+func commentLocation(comment *godiff.Comment) string {
+	location := "review"
+	if comment.Anchor.Path != "" {
+		location = comment.Anchor.Path
+	}
+
+	if comment.Anchor.Line != 0 {
+		location += fmt.Sprintf(":%d", comment.Anchor.Line)
+	}
+
+	return location
+}
+
+// normalizeCommentText brings comment text to the form it is sent in:
+// suggestion blocks are converted and wrapped paragraphs are joined back.
+func normalizeCommentText(text string, wrapWidth int) string {
+	return ReflowText(ConvertSuggestionBlocks(text), wrapWidth)
+}
+
 func trimCommentSpaces(text string) string {
 	return strings.TrimSpace(
 		reDanglingSpace.ReplaceAllString(
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/seletskiy/godiff"
@@ -49,6 +50,16 @@ func TestCompare(t *testing.T) {
 				},
 			},
 		},
+		{
+			"_test/without_comments.diff",
+			"_test/with_one_new_nested_reaction.diff",
+			[]map[string]interface{}{
+				{
+					"id":       int64(1234),
+					"emoticon": "thumbsup",
+				},
+			},
+		},
 		{
 			"_test/with_one_nested_stored_comment.diff",
 			"_test/with_one_stored_comment.diff",
@@ -94,6 +105,92 @@ func TestCompare(t *testing.T) {
 	}
 }
 
+func TestCompareKeepsMissingOutdatedComments(t *testing.T) {
+	current, err := parseReviewFile("_test/with_one_stored_comment.diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current.outdated = map[int64]bool{1234: true}
+
+	another, err := parseReviewFile("_test/without_comments.diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := current.Compare(another)
+	if len(changes) != 0 {
+		t.Fatalf("outdated comment should not be removed: %#v", changes)
+	}
+}
+
+func TestOutdatedMarker(t *testing.T) {
+	review := &Review{}
+	review.AddOutdatedComments(godiff.CommentsTree{
+		{Id: 12, Comments: godiff.CommentsTree{{Id: 3}}},
+	})
+
+	note := review.changeset.Diffs[0].Note
+	actual := readCommentIds(
+		"### "+strings.Replace(note, "\n", "\n### ", -1), reOutdatedMarker,
+	)
+
+	expected := map[int64]bool{3: true, 12: true}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected outdated comments\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestCompareAddsCommentOutsideOfDiffToFile(t *testing.T) {
+	fileDiff := &godiff.Diff{}
+	fileDiff.Source.ToString = "a.go"
+	fileDiff.Destination.ToString = "b.go"
+
+	current := &Review{}
+
+	another := &Review{}
+	another.changeset.Diffs = []*godiff.Diff{
+		fileDiff,
+		{FileComments: godiff.CommentsTree{{Text: "new"}}},
+	}
+
+	changes := current.Compare(another)
+	if len(changes) != 1 {
+		t.Fatalf("unexpected number of changes: %d", len(changes))
+	}
+
+	added, ok := changes[0].(FileCommentAdded)
+	if !ok {
+		t.Fatalf("unexpected change: %#v", changes[0])
+	}
+
+	if added.comment.Anchor.Path != "b.go" ||
+		added.comment.Anchor.SrcPath != "a.go" {
+		t.Fatalf("comment is added to wrong file: %#v", added.comment.Anchor)
+	}
+}
+
+func TestValidateChanges(t *testing.T) {
+	own := &godiff.Comment{Id: 1}
+	own.Author.Name = "me"
+
+	foreign := &godiff.Comment{Id: 2}
+	foreign.Author.Name = "someone"
+
+	changes := []ReviewChange{
+		CommentModified{own, own, 0},
+		CommentModified{&godiff.Comment{Id: 2}, foreign, 0},
+		CommentRemoved{foreign},
+		ReplyAdded{&godiff.Comment{}, foreign},
+	}
+
+	illegal := ValidateChanges(changes, "me")
+	if len(illegal) != 2 {
+		t.Fatalf("unexpected number of illegal changes: %d instead of 2",
+			len(illegal))
+	}
+}
+
 func compareTwoReviews(origFile, compareFile string) []ReviewChange {
 	a, err := parseReviewFile(origFile)
 	if err != nil {
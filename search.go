@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bndr/gopencils"
+)
+
+// PullRequestSearch describes criteria of searching pull requests across
+// repositories of the project. Empty fields do not filter anything.
+type PullRequestSearch struct {
+	State        string
+	Author       string
+	Participant  string
+	TargetBranch string
+	Text         string
+}
+
+// SearchPullRequests searches pull requests in all repositories of the
+// project until limit (if non-zero) is reached. If author or participant is
+// the current user, dashboard of Stash is searched instead, so there is no
+// need to request every repository.
+func (project Project) SearchPullRequests(
+	search PullRequestSearch, limit int,
+) ([]PullRequest, error) {
+	if search.isAboutUser(project.Auth.Username) {
+		return project.searchDashboard(search, limit)
+	}
+
+	repos, err := project.ListRepos("", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []PullRequest{}
+	for _, info := range repos {
+		repo := project.GetRepo(info.Slug)
+
+		prs, err := repo.SearchPullRequests(search, limit-len(result))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, prs...)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (repo *Repo) SearchPullRequests(
+	search PullRequestSearch, limit int,
+) ([]PullRequest, error) {
+	return searchPages(
+		repo.Api, search.getQuery(), search.Match, limit,
+		func(reply interface{}) *gopencils.Resource {
+			return repo.Resource.Res("pull-requests", reply)
+		},
+	)
+}
+
+func (project Project) searchDashboard(
+	search PullRequestSearch, limit int,
+) ([]PullRequest, error) {
+	return searchPages(
+		project.Api, search.getDashboardQuery(project.Auth.Username),
+		func(pr PullRequest) bool {
+			return strings.EqualFold(
+				pr.ToRef.Repository.Project.Key, project.Key(),
+			) && search.Match(pr)
+		},
+		limit,
+		func(reply interface{}) *gopencils.Resource {
+			return project.GetResource().Res("api/1.0").Res("dashboard").
+				Res("pull-requests", reply)
+		},
+	)
+}
+
+// searchPages requests pages of pull requests from resource until limit (if
+// non-zero) of matched ones is reached.
+func searchPages(
+	api *Api, query map[string]string, match func(PullRequest) bool,
+	limit int, getResource func(interface{}) *gopencils.Resource,
+) ([]PullRequest, error) {
+	result := []PullRequest{}
+
+	start := 0
+	for {
+		reply := struct {
+			pagedReply
+			Values []PullRequest
+		}{}
+
+		for key, value := range getPageQuery(start) {
+			query[key] = value
+		}
+
+		err := api.DoGet(getResource(&reply), query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range reply.Values {
+			if !match(pr) {
+				continue
+			}
+
+			result = append(result, pr)
+			if limit > 0 && len(result) >= limit {
+				return result, nil
+			}
+		}
+
+		if reply.IsLastPage || len(reply.Values) == 0 {
+			return result, nil
+		}
+
+		start = reply.NextPageStart
+	}
+}
+
+func (search PullRequestSearch) isAboutUser(user string) bool {
+	return user != "" && (strings.EqualFold(search.Author, user) ||
+		strings.EqualFold(search.Participant, user))
+}
+
+// getQuery returns query for Stash, which does most of filtering on the
+// server side; Match is still used for servers ignoring some parameters.
+func (search PullRequestSearch) getQuery() map[string]string {
+	query := map[string]string{
+		"state": "OPEN",
+	}
+
+	if search.State != "" {
+		query["state"] = strings.ToUpper(search.State)
+	}
+
+	if search.TargetBranch != "" {
+		query["at"] = search.getTargetRef()
+		query["direction"] = "INCOMING"
+	}
+
+	if search.Text != "" {
+		query["filterText"] = search.Text
+	}
+
+	index := 1
+	for _, role := range []struct {
+		name string
+		user string
+	}{
+		{"AUTHOR", search.Author},
+		{"PARTICIPANT", search.Participant},
+	} {
+		if role.user != "" {
+			query[fmt.Sprintf("role.%d", index)] = role.name
+			query[fmt.Sprintf("username.%d", index)] = role.user
+			index++
+		}
+	}
+
+	return query
+}
+
+// getDashboardQuery returns query for dashboard of Stash, which can filter
+// only by state and role of current user, so the rest is filtered by Match.
+func (search PullRequestSearch) getDashboardQuery(
+	user string,
+) map[string]string {
+	query := map[string]string{}
+
+	switch {
+	case search.State == "":
+		query["state"] = "OPEN"
+	case !strings.EqualFold(search.State, "all"):
+		query["state"] = strings.ToUpper(search.State)
+	}
+
+	if strings.EqualFold(search.Author, user) {
+		query["role"] = "AUTHOR"
+	}
+
+	return query
+}
+
+func (search PullRequestSearch) getTargetRef() string {
+	if strings.HasPrefix(search.TargetBranch, "refs/") {
+		return search.TargetBranch
+	}
+
+	return "refs/heads/" + search.TargetBranch
+}
+
+func (search PullRequestSearch) Match(pr PullRequest) bool {
+	if search.State != "" && !strings.EqualFold(search.State, "all") &&
+		!strings.EqualFold(pr.State, search.State) {
+		return false
+	}
+
+	if search.Author != "" &&
+		!strings.EqualFold(pr.Author.User.Name, search.Author) {
+		return false
+	}
+
+	if search.Participant != "" && !pr.HasParticipant(search.Participant) {
+		return false
+	}
+
+	if search.TargetBranch != "" && pr.ToRef.Id != search.getTargetRef() {
+		return false
+	}
+
+	if search.Text != "" && !matchFilter(
+		search.Text, pr.Title, pr.Description,
+	) {
+		return false
+	}
+
+	return true
+}
+
+// HasParticipant returns true if user is either author, reviewer or other
+// participant of pull request.
+func (pr PullRequest) HasParticipant(user string) bool {
+	names := []string{pr.Author.User.Name}
+	for _, reviewer := range pr.Reviewers {
+		names = append(names, reviewer.User.Name)
+	}
+
+	for _, participant := range pr.Participants {
+		names = append(names, participant.User.Name)
+	}
+
+	for _, name := range names {
+		if strings.EqualFold(name, user) {
+			return true
+		}
+	}
+
+	return false
+}
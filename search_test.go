@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPullRequestSearchMatch(t *testing.T) {
+	pr := PullRequest{}
+	err := json.Unmarshal([]byte(`{
+		"id": 1, "title": "Fix Release Build", "state": "OPEN",
+		"description": "Broken by new linker.",
+		"toRef": {"id": "refs/heads/release/2.3"},
+		"author": {"user": {"name": "alice"}},
+		"reviewers": [{"user": {"name": "bob"}}],
+		"participants": [{"user": {"name": "carol"}}]
+	}`), &pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		search  PullRequestSearch
+		matched bool
+	}{
+		{PullRequestSearch{}, true},
+		{PullRequestSearch{State: "all"}, true},
+		{PullRequestSearch{State: "merged"}, false},
+		{PullRequestSearch{Author: "alice"}, true},
+		{PullRequestSearch{Author: "bob"}, false},
+		{PullRequestSearch{Participant: "bob"}, true},
+		{PullRequestSearch{Participant: "carol"}, true},
+		{PullRequestSearch{Participant: "dave"}, false},
+		{PullRequestSearch{TargetBranch: "release/2.3"}, true},
+		{PullRequestSearch{TargetBranch: "master"}, false},
+		{PullRequestSearch{Text: "release"}, true},
+		{PullRequestSearch{Text: "LINKER"}, true},
+		{PullRequestSearch{Text: "feature"}, false},
+	}
+
+	for _, test := range tests {
+		if test.search.Match(pr) != test.matched {
+			t.Fatalf("unexpected match result for %#v", test.search)
+		}
+	}
+}
+
+func TestPullRequestSearchQuery(t *testing.T) {
+	search := PullRequestSearch{
+		State:        "all",
+		Participant:  "bob",
+		TargetBranch: "master",
+		Text:         "fix",
+	}
+
+	expected := map[string]string{
+		"state":      "ALL",
+		"at":         "refs/heads/master",
+		"direction":  "INCOMING",
+		"filterText": "fix",
+		"role.1":     "PARTICIPANT",
+		"username.1": "bob",
+	}
+
+	if actual := search.getQuery(); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected query\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestPullRequestSearchDashboardQuery(t *testing.T) {
+	tests := []struct {
+		search    PullRequestSearch
+		dashboard bool
+		expected  map[string]string
+	}{
+		{
+			PullRequestSearch{Author: "bob"},
+			false,
+			map[string]string{"state": "OPEN"},
+		},
+		{
+			PullRequestSearch{State: "all", Participant: "alice"},
+			true,
+			map[string]string{},
+		},
+		{
+			PullRequestSearch{State: "merged", Author: "Alice"},
+			true,
+			map[string]string{"state": "MERGED", "role": "AUTHOR"},
+		},
+	}
+
+	for _, test := range tests {
+		if test.search.isAboutUser("alice") != test.dashboard {
+			t.Fatalf("unexpected choice of dashboard for %#v", test.search)
+		}
+
+		actual := test.search.getDashboardQuery("alice")
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("unexpected query\n%#v\n%#v", test.expected, actual)
+		}
+	}
+}
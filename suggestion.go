@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/seletskiy/godiff"
+)
+
+const suggestionFence = "```"
+
+var reSuggestionOpen = regexp.MustCompile(`^~~~\s*suggestion$`)
+
+var reSuggestionClose = regexp.MustCompile(`^~~~$`)
+
+type Suggestion struct {
+	Path    string
+	Line    int64
+	Origin  string
+	Replace []string
+	Comment *godiff.Comment
+}
+
+type suggestionsByLine []Suggestion
+
+func (s suggestionsByLine) Len() int           { return len(s) }
+func (s suggestionsByLine) Less(i, j int) bool { return s[i].Line < s[j].Line }
+func (s suggestionsByLine) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s Suggestion) String() string {
+	return fmt.Sprintf(
+		"Suggestion by %s for %s:%d\n%s\n%s",
+		s.Comment.Author.DisplayName, s.Path, s.Line,
+		indent(s.Origin, " - "),
+		indent(strings.Join(s.Replace, "\n"), " + "),
+	)
+}
+
+// ConvertSuggestionBlocks replaces ash-style suggestion blocks, fenced by
+// '~~~suggestion' and '~~~', with Stash markdown suggestion blocks.
+// Unterminated blocks are left untouched.
+func ConvertSuggestionBlocks(text string) string {
+	lines := strings.Split(text, "\n")
+	result := make([]string, 0, len(lines))
+
+	inside := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inside && reSuggestionOpen.MatchString(trimmed):
+			inside = true
+			result = append(result, suggestionFence+"suggestion")
+			continue
+		case inside && reSuggestionClose.MatchString(trimmed):
+			inside = false
+			result = append(result, suggestionFence)
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	if inside {
+		return text
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// ParseSuggestionBlocks returns contents of every Stash markdown suggestion
+// block found in given comment text.
+func ParseSuggestionBlocks(text string) [][]string {
+	blocks := [][]string{}
+
+	var block []string
+	inside := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inside && trimmed == suggestionFence+"suggestion":
+			inside = true
+			block = []string{}
+		case inside && trimmed == suggestionFence:
+			inside = false
+			blocks = append(blocks, block)
+		case inside:
+			block = append(block, line)
+		}
+	}
+
+	return blocks
+}
+
+func (pr *PullRequest) GetSuggestions() ([]Suggestion, error) {
+	changeset := godiff.Changeset{}
+
+	err := pr.DoGet(pr.Resource.Res("diff", &changeset))
+	if err != nil {
+		return nil, err
+	}
+
+	attachLineComments(&changeset)
+
+	suggestions := []Suggestion{}
+
+	changeset.ForEachLine(
+		func(
+			diff *godiff.Diff, _ *godiff.Hunk,
+			segment *godiff.Segment, line *godiff.Line,
+		) error {
+			path := diff.Destination.ToString
+			if path == "" || segment.Type == godiff.SegmentTypeRemoved {
+				return nil
+			}
+
+			for _, comment := range line.Comments {
+				for _, block := range ParseSuggestionBlocks(comment.Text) {
+					suggestions = append(suggestions, Suggestion{
+						Path:    path,
+						Line:    line.Destination,
+						Origin:  line.Line,
+						Replace: block,
+						Comment: comment,
+					})
+				}
+			}
+
+			return nil
+		})
+
+	return suggestions, nil
+}
+
+// MakeSuggestionsPatch builds zero-context unified diff, which replaces
+// every suggested line with suggestion contents. Only first suggestion is
+// taken for every line. Result should be applied with --unidiff-zero.
+func MakeSuggestionsPatch(suggestions []Suggestion) string {
+	byPath := map[string][]Suggestion{}
+	paths := []string{}
+
+	for _, suggestion := range suggestions {
+		if _, ok := byPath[suggestion.Path]; !ok {
+			paths = append(paths, suggestion.Path)
+		}
+
+		byPath[suggestion.Path] = append(byPath[suggestion.Path], suggestion)
+	}
+
+	patch := ""
+	for _, path := range paths {
+		fileSuggestions := byPath[path]
+		sort.Stable(suggestionsByLine(fileSuggestions))
+
+		patch += fmt.Sprintf("--- a/%s\n+++ b/%s\n", path, path)
+
+		offset := int64(0)
+		lastLine := int64(0)
+		for _, suggestion := range fileSuggestions {
+			if suggestion.Line == lastLine {
+				continue
+			}
+
+			lastLine = suggestion.Line
+
+			count := int64(len(suggestion.Replace))
+			newStart := suggestion.Line + offset
+			if count == 0 {
+				newStart--
+			}
+
+			patch += fmt.Sprintf(
+				"@@ -%d,1 +%d,%d @@\n-%s\n",
+				suggestion.Line, newStart, count, suggestion.Origin,
+			)
+
+			for _, line := range suggestion.Replace {
+				patch += "+" + line + "\n"
+			}
+
+			offset += count - 1
+		}
+	}
+
+	return patch
+}
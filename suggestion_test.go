@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/godiff"
+)
+
+func TestConvertSuggestionBlocks(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{
+			"use this:\n~~~suggestion\nfoo()\n~~~\nplease",
+			"use this:\n```suggestion\nfoo()\n```\nplease",
+		},
+		{
+			"unterminated:\n~~~suggestion\nfoo()",
+			"unterminated:\n~~~suggestion\nfoo()",
+		},
+		{
+			"~~~\nnot a suggestion\n~~~",
+			"~~~\nnot a suggestion\n~~~",
+		},
+	}
+
+	for _, test := range tests {
+		actual := ConvertSuggestionBlocks(test.text)
+		if actual != test.expected {
+			t.Fatalf("unexpected conversion result\n%q\n%q",
+				test.expected, actual)
+		}
+	}
+}
+
+func TestParseSuggestionBlocks(t *testing.T) {
+	actual := ParseSuggestionBlocks(
+		"a\n```suggestion\nfoo()\nbar()\n```\nb\n```suggestion\n```",
+	)
+
+	expected := [][]string{{"foo()", "bar()"}, {}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected blocks\n%#v\n%#v", expected, actual)
+	}
+}
+
+func TestMakeSuggestionsPatch(t *testing.T) {
+	actual := MakeSuggestionsPatch([]Suggestion{
+		{Path: "a.go", Line: 10, Origin: "ten", Replace: []string{}},
+		{Path: "a.go", Line: 3, Origin: "three", Replace: []string{"x", "y"}},
+		{Path: "a.go", Line: 3, Origin: "three", Replace: []string{"z"}},
+	})
+
+	expected := "--- a/a.go\n+++ b/a.go\n" +
+		"@@ -3,1 +3,2 @@\n-three\n+x\n+y\n" +
+		"@@ -10,1 +10,0 @@\n-ten\n"
+
+	if actual != expected {
+		t.Fatalf("unexpected patch\n%s\n%s", expected, actual)
+	}
+}
+
+func TestMatchCommentChangeWithSuggestion(t *testing.T) {
+	existing := &godiff.Comment{
+		Id:   1,
+		Text: "use this:\n~~~suggestion\nfoo()\n~~~",
+	}
+
+	edited := &godiff.Comment{
+		Id:   1,
+		Text: ConvertSuggestionBlocks("use this:\n~~~suggestion\nfoo()\n~~~"),
+	}
+
+	change := matchCommentChange(
+		[]*godiff.Comment{existing}, edited, nil, 0,
+	)
+	if change != nil {
+		t.Fatalf("unmodified comment is reported as changed: %#v", change)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// InboxEvent describes change of inbox between two polls. It is passed to
+// the --on-change command as JSON.
+type InboxEvent struct {
+	Type        string
+	PullRequest string
+	URL         string
+	Title       string
+	Author      string
+	User        string `json:",omitempty"`
+	Comments    int64  `json:",omitempty"`
+}
+
+func (event InboxEvent) String() string {
+	switch event.Type {
+	case "added":
+		return fmt.Sprintf("%s: new pull request by %s: %s",
+			event.PullRequest, event.Author, event.Title)
+	case "removed":
+		return fmt.Sprintf("%s: removed from inbox", event.PullRequest)
+	case "commented":
+		return fmt.Sprintf("%s: %d new comment(s)",
+			event.PullRequest, event.Comments)
+	case "approved":
+		return fmt.Sprintf("%s: approved by %s",
+			event.PullRequest, event.User)
+	}
+
+	return fmt.Sprintf("%s: %s", event.PullRequest, event.Type)
+}
+
+// DiffInbox returns events, which happened between previous and current
+// inbox snapshots: pull requests added to or removed from inbox, new
+// comments and approvals.
+func DiffInbox(previous []PullRequest, current []PullRequest) []InboxEvent {
+	known := map[string]PullRequest{}
+	for _, pr := range previous {
+		known[getPullRequestSlug(pr)] = pr
+	}
+
+	events := []InboxEvent{}
+	for _, pr := range current {
+		slug := getPullRequestSlug(pr)
+
+		old, ok := known[slug]
+		if !ok {
+			events = append(events, newInboxEvent("added", pr))
+			continue
+		}
+
+		delete(known, slug)
+
+		if pr.Properties.CommentCount > old.Properties.CommentCount {
+			event := newInboxEvent("commented", pr)
+			event.Comments = pr.Properties.CommentCount -
+				old.Properties.CommentCount
+			events = append(events, event)
+		}
+
+		approved := map[string]bool{}
+		for _, reviewer := range old.Reviewers {
+			approved[reviewer.User.Name] = reviewer.Approved
+		}
+
+		for _, reviewer := range pr.Reviewers {
+			if reviewer.Approved && !approved[reviewer.User.Name] {
+				event := newInboxEvent("approved", pr)
+				event.User = reviewer.User.Name
+				events = append(events, event)
+			}
+		}
+	}
+
+	for _, pr := range previous {
+		if _, ok := known[getPullRequestSlug(pr)]; ok {
+			events = append(events, newInboxEvent("removed", pr))
+		}
+	}
+
+	return events
+}
+
+// ArrangeInboxEvents returns events of listed pull requests only, in the
+// order of that list.
+func ArrangeInboxEvents(
+	events []InboxEvent, listed []PullRequest,
+) []InboxEvent {
+	result := []InboxEvent{}
+	arranged := map[string]bool{}
+	for _, pr := range listed {
+		slug := getPullRequestSlug(pr)
+		if arranged[slug] {
+			continue
+		}
+
+		arranged[slug] = true
+
+		for _, event := range events {
+			if event.PullRequest == slug {
+				result = append(result, event)
+			}
+		}
+	}
+
+	return result
+}
+
+func newInboxEvent(eventType string, pr PullRequest) InboxEvent {
+	event := InboxEvent{
+		Type:        eventType,
+		PullRequest: getPullRequestSlug(pr),
+		Title:       pr.Title,
+		Author:      pr.Author.User.Name,
+	}
+
+	if len(pr.Links.Self) > 0 {
+		event.URL = pr.Links.Self[0].Href
+	}
+
+	return event
+}
+
+// runHook runs command via shell, passing event as JSON to its stdin.
+func runHook(command string, event InboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("running hook: %s %s", command, data)
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func getPullRequestSlug(pr PullRequest) string {
+	return fmt.Sprintf("%s/%s/%d",
+		strings.ToLower(pr.ToRef.Repository.Project.Key),
+		pr.ToRef.Repository.Slug,
+		pr.Id,
+	)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffInbox(t *testing.T) {
+	previous := []PullRequest{}
+	current := []PullRequest{}
+
+	err := json.Unmarshal([]byte(`[
+		{
+			"id": 1, "toRef": {"repository": {"slug": "r", "project": {"key": "P"}}},
+			"reviewers": [{"approved": false, "user": {"name": "bob"}}],
+			"properties": {"commentCount": 1}
+		},
+		{
+			"id": 2, "toRef": {"repository": {"slug": "r", "project": {"key": "P"}}}
+		}
+	]`), &previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal([]byte(`[
+		{
+			"id": 1, "toRef": {"repository": {"slug": "r", "project": {"key": "P"}}},
+			"reviewers": [{"approved": true, "user": {"name": "bob"}}],
+			"properties": {"commentCount": 3}
+		},
+		{
+			"id": 3, "title": "New one", "author": {"user": {"name": "alice"}},
+			"toRef": {"repository": {"slug": "r", "project": {"key": "P"}}},
+			"links": {"self": [{"href": "http://stash/3"}]}
+		}
+	]`), &current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []InboxEvent{
+		{Type: "commented", PullRequest: "p/r/1", Comments: 2},
+		{Type: "approved", PullRequest: "p/r/1", User: "bob"},
+		{
+			Type: "added", PullRequest: "p/r/3", URL: "http://stash/3",
+			Title: "New one", Author: "alice",
+		},
+		{Type: "removed", PullRequest: "p/r/2"},
+	}
+
+	actual := DiffInbox(previous, current)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected events\n%#v\n%#v", expected, actual)
+	}
+
+	if events := DiffInbox(current, current); len(events) != 0 {
+		t.Fatalf("unexpected events for the same inbox: %#v", events)
+	}
+}
+
+func TestWatchInboxIgnoresLimit(t *testing.T) {
+	inbox := []PullRequest{}
+	err := json.Unmarshal([]byte(`[
+		{"id": 1, "toRef": {"repository": {"slug": "r", "project": {"key": "P"}}},
+			"updatedDate": 1},
+		{"id": 2, "toRef": {"repository": {"slug": "r", "project": {"key": "P"}}},
+			"updatedDate": 2},
+		{"id": 3, "toRef": {"repository": {"slug": "r", "project": {"key": "P"}}},
+			"updatedDate": 3, "properties": {"commentCount": 1}}
+	]`), &inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := PullRequestFilter{Sort: "updated", Limit: 2}
+
+	previous := filter.Select(inbox[:2])
+	current := filter.Select(inbox)
+
+	expected := []InboxEvent{{Type: "added", PullRequest: "p/r/3"}}
+
+	actual := ArrangeInboxEvents(
+		DiffInbox(previous, current),
+		append(filter.Arrange(current), filter.Arrange(previous)...),
+	)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("unexpected events\n%#v\n%#v", expected, actual)
+	}
+}
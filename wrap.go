@@ -0,0 +1,135 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	reListItem     = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	reCodeFence    = regexp.MustCompile("^\\s*(```|~~~)")
+	reVerbatimLine = regexp.MustCompile(`^\s*([#>|]|\s{4}|\t)`)
+	reBlockStarter = regexp.MustCompile("^([-*+>#|]|\\d+[.)]|```|~~~)")
+)
+
+// WrapText breaks long paragraphs and list items of markdown text into lines
+// no longer than width. Code blocks, headers, quotes and tables are left as
+// is.
+func WrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+
+	result := []string{}
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if reCodeFence.MatchString(line) {
+			inFence = !inFence
+		}
+
+		if inFence || reCodeFence.MatchString(line) ||
+			reVerbatimLine.MatchString(line) && !reListItem.MatchString(line) {
+			result = append(result, line)
+			continue
+		}
+
+		result = append(result, wrapLine(line, width)...)
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// ReflowText joins paragraphs and list items, which were broken into
+// several lines either by WrapText or by hand, back into single lines. Code
+// blocks, headers, quotes and tables are left as is.
+func ReflowText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+
+	result := []string{}
+	paragraph := []string{}
+	inFence := false
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+
+		result = append(result, strings.Join(paragraph, " "))
+		paragraph = []string{}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case reCodeFence.MatchString(line):
+			flush()
+			inFence = !inFence
+			result = append(result, line)
+
+		case inFence:
+			result = append(result, line)
+
+		case strings.TrimSpace(line) == "":
+			flush()
+			result = append(result, line)
+
+		case reListItem.MatchString(line):
+			flush()
+			paragraph = []string{strings.TrimRight(line, " \t")}
+
+		case len(paragraph) > 0 && !reBlockStarter.MatchString(
+			strings.TrimSpace(line),
+		):
+			paragraph = append(paragraph, strings.TrimSpace(line))
+
+		case reVerbatimLine.MatchString(line):
+			flush()
+			result = append(result, line)
+
+		default:
+			flush()
+			paragraph = []string{strings.TrimRight(line, " \t")}
+		}
+	}
+
+	flush()
+
+	return strings.Join(result, "\n")
+}
+
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return []string{line}
+	}
+
+	indentation := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	prefix := indentation
+	if marker := reListItem.FindString(line); marker != "" {
+		prefix = strings.Repeat(" ", utf8.RuneCountInString(marker))
+	}
+
+	lines := []string{}
+	current := indentation + words[0]
+
+	for _, word := range words[1:] {
+		length := utf8.RuneCountInString(current) + 1 +
+			utf8.RuneCountInString(word)
+
+		if length > width && !reBlockStarter.MatchString(word) {
+			lines = append(lines, current)
+			current = prefix + word
+		} else {
+			current += " " + word
+		}
+	}
+
+	return append(lines, current)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		text     string
+		width    int
+		expected string
+	}{
+		{
+			"one two three four five",
+			10,
+			"one two\nthree four\nfive",
+		},
+		{
+			"- one two three four",
+			10,
+			"- one two\n  three\n  four",
+		},
+		{
+			"```\none two three four five\n```",
+			10,
+			"```\none two three four five\n```",
+		},
+		{
+			"one two - three",
+			8,
+			"one two -\nthree",
+		},
+		{
+			"раз два три четыре пять",
+			10,
+			"раз два\nтри четыре\nпять",
+		},
+	}
+
+	for _, test := range tests {
+		actual := WrapText(test.text, test.width)
+		if actual != test.expected {
+			t.Fatalf("unexpected wrap result\n%q\n%q", test.expected, actual)
+		}
+
+		reflowed := ReflowText(actual, test.width)
+		if reflowed != test.text {
+			t.Fatalf("unexpected reflow result\n%q\n%q", test.text, reflowed)
+		}
+	}
+}
+
+func TestReflowText(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{
+			"first paragraph\nis long\n\nsecond\nparagraph is long",
+			"first paragraph is long\n\nsecond paragraph is long",
+		},
+		{
+			"list:\n- one of many\n  more\n- two\n1. three",
+			"list:\n- one of many more\n- two\n1. three",
+		},
+		{
+			"code:\n\n    a\n    b\n\n~~~\nc\nd\n~~~",
+			"code:\n\n    a\n    b\n\n~~~\nc\nd\n~~~",
+		},
+		{
+			"short\nlines\n- are\n  joined too",
+			"short lines\n- are joined too",
+		},
+		{
+			"a\nb\nc\n\n# header\n> quote\n| table |",
+			"a b c\n\n# header\n> quote\n| table |",
+		},
+	}
+
+	for _, test := range tests {
+		actual := ReflowText(test.text, 15)
+		if actual != test.expected {
+			t.Fatalf("unexpected reflow result\n%q\n%q", test.expected, actual)
+		}
+	}
+}